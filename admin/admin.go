@@ -0,0 +1,292 @@
+// Package admin 提供查看和操作 MemoryCache 的HTTP管理接口, 响应为JSON格式.
+// Package admin provides an HTTP handler with JSON endpoints to inspect and operate a MemoryCache.
+//
+// 接口, 路径为挂载点之后的最后一段:
+// Endpoints, matched by the last segment of the path after the mount point:
+//
+//	GET    keys?cursor=0&count=100      增量遍历键 / list keys with cursor pagination
+//	GET    key?key=k                    查看值和剩余存活时间 / get the value and the remaining time to live
+//	PUT    key?key=k&ttl=10s  (body)    写入值, 请求体为值 / set the value from the request body
+//	DELETE key?key=k                    删除键 / delete a key
+//	GET    ttl?key=k                    查看剩余存活时间 / get the remaining time to live
+//	GET    buckets                      存储桶的占用和堆顶 / per-bucket occupancy and heap top
+//	POST   clear                        清空缓存 / clear the cache
+//
+// 剩余存活时间以毫秒为单位, -1表示永不过期. 查看操作不记录统计, 也不影响淘汰顺序.
+// Times to live are in milliseconds and -1 means never expire.
+// Inspecting does not record statistics or change the eviction order.
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lxzan/memorycache"
+)
+
+const (
+	// keys 接口的默认和最大遍历数量
+	defaultCount = 100
+	maxCount     = 10000
+
+	// 写入值的请求体的最大长度
+	maxBodySize = 32 << 20
+)
+
+// Handler HTTP管理接口
+// HTTP admin handler
+type Handler[K comparable, V any] struct {
+	cache  *memorycache.MemoryCache[K, V]
+	keys   Codec[K]
+	values Codec[V]
+}
+
+// NewHandler 创建管理接口. string, []byte 和 int 类型使用对应的文本格式, 其他类型默认使用JSON编解码.
+// Create an admin handler. string, []byte and int use their text forms, other types are encoded as JSON by default.
+func NewHandler[K comparable, V any](cache *memorycache.MemoryCache[K, V]) *Handler[K, V] {
+	return &Handler[K, V]{cache: cache, keys: defaultCodec[K](), values: defaultCodec[V]()}
+}
+
+// WithKeyCodec 设置键的编解码器
+// Set the key codec
+func (c *Handler[K, V]) WithKeyCodec(codec Codec[K]) *Handler[K, V] {
+	c.keys = codec
+	return c
+}
+
+// WithValueCodec 设置值的编解码器
+// Set the value codec
+func (c *Handler[K, V]) WithValueCodec(codec Codec[V]) *Handler[K, V] {
+	c.values = codec
+	return c
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type keysResponse struct {
+	Keys   []string `json:"keys"`
+	Cursor uint64   `json:"cursor"`
+}
+
+type keyResponse struct {
+	Key   string  `json:"key"`
+	Value *string `json:"value,omitempty"`
+	TTL   *int64  `json:"ttl_ms,omitempty"`
+	Exist *bool   `json:"exist,omitempty"`
+}
+
+type bucketResponse struct {
+	Index   int   `json:"index"`
+	Len     int   `json:"len"`
+	HeapLen int   `json:"heap_len"`
+	Cost    int64 `json:"cost"`
+
+	// 堆顶元素的过期时间, Unix毫秒时间戳. 存储桶为空或永不过期时为null
+	NextExpireAt *int64 `json:"next_expire_at"`
+}
+
+type bucketsResponse struct {
+	Len     int              `json:"len"`
+	Cost    int64            `json:"cost"`
+	Buckets []bucketResponse `json:"buckets"`
+}
+
+func (c *Handler[K, V]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var path = strings.TrimSuffix(r.URL.Path, "/")
+	switch name := path[strings.LastIndexByte(path, '/')+1:]; name {
+	case "keys":
+		c.allow(w, r, c.listKeys, http.MethodGet)
+	case "key":
+		c.allow(w, r, c.key, http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete)
+	case "ttl":
+		c.allow(w, r, c.ttl, http.MethodGet)
+	case "buckets":
+		c.allow(w, r, c.buckets, http.MethodGet)
+	case "clear":
+		c.allow(w, r, c.clear, http.MethodPost)
+	default:
+		writeError(w, http.StatusNotFound, "unknown endpoint")
+	}
+}
+
+// 检查请求方法
+func (c *Handler[K, V]) allow(w http.ResponseWriter, r *http.Request, fn http.HandlerFunc, methods ...string) {
+	for _, method := range methods {
+		if r.Method == method {
+			fn(w, r)
+			return
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
+}
+
+// 剩余存活时间, 毫秒, -1表示永不过期
+func ttlMillis(expireAt time.Time) *int64 {
+	var ms int64 = -1
+	if !expireAt.IsZero() {
+		if ms = time.Until(expireAt).Milliseconds(); ms < 0 {
+			ms = 0
+		}
+	}
+	return &ms
+}
+
+// 解析URL参数中的键
+func (c *Handler[K, V]) parseKey(w http.ResponseWriter, r *http.Request) (key K, text string, ok bool) {
+	var query = r.URL.Query()
+	if !query.Has("key") {
+		writeError(w, http.StatusBadRequest, "missing key")
+		return key, "", false
+	}
+	text = query.Get("key")
+	key, err := c.keys.Decode(text)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid key: "+err.Error())
+		return key, "", false
+	}
+	return key, text, true
+}
+
+func (c *Handler[K, V]) listKeys(w http.ResponseWriter, r *http.Request) {
+	var query = r.URL.Query()
+	var cursor uint64
+	var count = defaultCount
+	if s := query.Get("cursor"); s != "" {
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+		cursor = n
+	}
+	if s := query.Get("count"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > maxCount {
+			writeError(w, http.StatusBadRequest, "invalid count")
+			return
+		}
+		count = n
+	}
+
+	keys, next := c.cache.Scan(cursor, count)
+	var resp = keysResponse{Keys: make([]string, 0, len(keys)), Cursor: next}
+	for _, key := range keys {
+		text, err := c.keys.Encode(key)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "encode key: "+err.Error())
+			return
+		}
+		resp.Keys = append(resp.Keys, text)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (c *Handler[K, V]) key(w http.ResponseWriter, r *http.Request) {
+	key, text, ok := c.parseKey(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		value, expireAt, exist := c.cache.Peek(key)
+		if !exist {
+			writeError(w, http.StatusNotFound, "key not found")
+			return
+		}
+		encoded, err := c.values.Encode(value)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "encode value: "+err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, keyResponse{Key: text, Value: &encoded, TTL: ttlMillis(expireAt)})
+
+	case http.MethodDelete:
+		var exist = c.cache.Delete(key)
+		writeJSON(w, http.StatusOK, keyResponse{Key: text, Exist: &exist})
+
+	default:
+		var ttl time.Duration
+		if s := r.URL.Query().Get("ttl"); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil || d < 0 {
+				writeError(w, http.StatusBadRequest, "invalid ttl")
+				return
+			}
+			ttl = d
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				writeError(w, http.StatusRequestEntityTooLarge, "value too large")
+				return
+			}
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		value, err := c.values.Decode(string(body))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid value: "+err.Error())
+			return
+		}
+		var exist = c.cache.Set(key, value, ttl)
+		writeJSON(w, http.StatusOK, keyResponse{Key: text, Exist: &exist})
+	}
+}
+
+func (c *Handler[K, V]) ttl(w http.ResponseWriter, r *http.Request) {
+	key, text, ok := c.parseKey(w, r)
+	if !ok {
+		return
+	}
+	_, expireAt, exist := c.cache.Peek(key)
+	if !exist {
+		writeError(w, http.StatusNotFound, "key not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, keyResponse{Key: text, TTL: ttlMillis(expireAt)})
+}
+
+func (c *Handler[K, V]) buckets(w http.ResponseWriter, r *http.Request) {
+	var list = c.cache.Buckets()
+	var resp = bucketsResponse{Buckets: make([]bucketResponse, 0, len(list))}
+	for i, item := range list {
+		var bucket = bucketResponse{Index: i, Len: item.Len, HeapLen: item.HeapLen, Cost: item.Cost}
+		if item.HeapLen > 0 && item.NextExpireAt != math.MaxInt64 {
+			var expireAt = item.NextExpireAt
+			bucket.NextExpireAt = &expireAt
+		}
+		resp.Len += item.Len
+		resp.Cost += item.Cost
+		resp.Buckets = append(resp.Buckets, bucket)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (c *Handler[K, V]) clear(w http.ResponseWriter, r *http.Request) {
+	var n = c.cache.Len()
+	c.cache.Clear()
+	writeJSON(w, http.StatusOK, struct {
+		Cleared int `json:"cleared"`
+	}{Cleared: n})
+}
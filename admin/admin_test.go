@@ -0,0 +1,182 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lxzan/memorycache"
+	"github.com/stretchr/testify/assert"
+)
+
+func do(h http.Handler, method, target, body string) (int, map[string]any) {
+	var req = httptest.NewRequest(method, target, strings.NewReader(body))
+	var rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var resp map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec.Code, resp
+}
+
+func TestHandler_Key(t *testing.T) {
+	t.Run("", func(t *testing.T) {
+		var mc = memorycache.New[string, []byte](memorycache.WithStats(true))
+		defer mc.Stop()
+		var h = NewHandler(mc)
+
+		code, resp := do(h, http.MethodGet, "/debug/cache/key?key=a", "")
+		assert.Equal(t, code, http.StatusNotFound)
+		assert.Equal(t, resp["error"], "key not found")
+
+		code, resp = do(h, http.MethodPut, "/debug/cache/key?key=a&ttl=1h", "hello")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, resp["exist"], false)
+		v, _, _ := mc.Peek("a")
+		assert.Equal(t, string(v), "hello")
+
+		code, resp = do(h, http.MethodGet, "/debug/cache/key?key=a", "")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, resp["value"], "hello")
+		var ttl = resp["ttl_ms"].(float64)
+		assert.True(t, ttl > float64(59*time.Minute/time.Millisecond) && ttl <= float64(time.Hour/time.Millisecond))
+
+		code, resp = do(h, http.MethodPost, "/debug/cache/key?key=a", "world")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, resp["exist"], true)
+		_, resp = do(h, http.MethodGet, "/debug/cache/ttl?key=a", "")
+		assert.Equal(t, resp["ttl_ms"], float64(-1))
+
+		// 查看不记录统计
+		assert.Equal(t, mc.Stats().Hits+mc.Stats().Misses, uint64(0))
+
+		code, resp = do(h, http.MethodDelete, "/debug/cache/key?key=a", "")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, resp["exist"], true)
+		code, _ = do(h, http.MethodGet, "/debug/cache/ttl?key=a", "")
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+
+	t.Run("bad request", func(t *testing.T) {
+		var mc = memorycache.New[int, map[string]int]()
+		defer mc.Stop()
+		var h = NewHandler(mc)
+
+		code, resp := do(h, http.MethodGet, "/key", "")
+		assert.Equal(t, code, http.StatusBadRequest)
+		assert.Equal(t, resp["error"], "missing key")
+		code, _ = do(h, http.MethodGet, "/key?key=x", "")
+		assert.Equal(t, code, http.StatusBadRequest)
+		code, _ = do(h, http.MethodPut, "/key?key=1&ttl=x", "{}")
+		assert.Equal(t, code, http.StatusBadRequest)
+		code, _ = do(h, http.MethodPut, "/key?key=1", "{")
+		assert.Equal(t, code, http.StatusBadRequest)
+		code, _ = do(h, http.MethodPatch, "/key?key=1", "")
+		assert.Equal(t, code, http.StatusMethodNotAllowed)
+		code, _ = do(h, http.MethodGet, "/clear", "")
+		assert.Equal(t, code, http.StatusMethodNotAllowed)
+		code, _ = do(h, http.MethodGet, "/unknown", "")
+		assert.Equal(t, code, http.StatusNotFound)
+
+		code, _ = do(h, http.MethodPut, "/key?key=1", `{"a":1}`)
+		assert.Equal(t, code, http.StatusOK)
+		_, resp = do(h, http.MethodGet, "/key?key=1", "")
+		assert.Equal(t, resp["value"], `{"a":1}`)
+	})
+}
+
+func TestHandler_Keys(t *testing.T) {
+	t.Run("", func(t *testing.T) {
+		var mc = memorycache.New[string, int](memorycache.WithBucketNum(4))
+		defer mc.Stop()
+		var h = NewHandler(mc)
+		for i := 0; i < 100; i++ {
+			mc.Set(strconv.Itoa(i), i, 0)
+		}
+
+		var keys = make(map[string]bool)
+		var cursor = "0"
+		for {
+			code, resp := do(h, http.MethodGet, "/keys?count=7&cursor="+cursor, "")
+			assert.Equal(t, code, http.StatusOK)
+			for _, key := range resp["keys"].([]any) {
+				keys[key.(string)] = true
+			}
+			cursor = strconv.FormatUint(uint64(resp["cursor"].(float64)), 10)
+			if cursor == "0" {
+				break
+			}
+		}
+		assert.Equal(t, len(keys), 100)
+
+		code, _ := do(h, http.MethodGet, "/keys?cursor=x", "")
+		assert.Equal(t, code, http.StatusBadRequest)
+		code, _ = do(h, http.MethodGet, "/keys?count=0", "")
+		assert.Equal(t, code, http.StatusBadRequest)
+	})
+}
+
+func TestHandler_Buckets(t *testing.T) {
+	t.Run("", func(t *testing.T) {
+		var mc = memorycache.New[string, int](memorycache.WithBucketNum(2))
+		defer mc.Stop()
+		var h = NewHandler(mc)
+		mc.Set("a", 1, time.Hour)
+		mc.Set("b", 1, 0)
+
+		code, resp := do(h, http.MethodGet, "/buckets/", "")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, resp["len"], float64(2))
+		var buckets = resp["buckets"].([]any)
+		assert.Equal(t, len(buckets), 2)
+		var expireAt int
+		for _, item := range buckets {
+			if item.(map[string]any)["next_expire_at"] != nil {
+				expireAt++
+			}
+		}
+		assert.True(t, expireAt >= 1)
+
+		code, resp = do(h, http.MethodPost, "/clear", "")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, resp["cleared"], float64(2))
+		assert.Equal(t, mc.Len(), 0)
+	})
+}
+
+type upperCodec struct{}
+
+func (upperCodec) Encode(v string) (string, error) { return strings.ToUpper(v), nil }
+
+func (upperCodec) Decode(s string) (string, error) { return strings.ToLower(s), nil }
+
+func TestHandler_Codec(t *testing.T) {
+	t.Run("", func(t *testing.T) {
+		var mc = memorycache.New[string, string]()
+		defer mc.Stop()
+		var h = NewHandler(mc).WithKeyCodec(upperCodec{}).WithValueCodec(JSONCodec[string]{})
+		code, _ := do(h, http.MethodPut, "/key?key=ABC", `"x"`)
+		assert.Equal(t, code, http.StatusOK)
+		v, _, _ := mc.Peek("abc")
+		assert.Equal(t, v, "x")
+		_, resp := do(h, http.MethodGet, "/key?key=ABC", "")
+		assert.Equal(t, resp["key"], "ABC")
+		assert.Equal(t, resp["value"], `"x"`)
+		_, resp = do(h, http.MethodGet, "/keys", "")
+		assert.Equal(t, resp["keys"], []any{"ABC"})
+	})
+
+	t.Run("default", func(t *testing.T) {
+		_, ok := defaultCodec[string]().(StringCodec)
+		assert.True(t, ok)
+		_, ok = defaultCodec[[]byte]().(BytesCodec)
+		assert.True(t, ok)
+		_, ok = defaultCodec[int]().(IntCodec)
+		assert.True(t, ok)
+		_, ok = defaultCodec[float64]().(JSONCodec[float64])
+		assert.True(t, ok)
+	})
+}
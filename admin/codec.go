@@ -0,0 +1,66 @@
+package admin
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// Codec 键或值与字符串之间的编解码器. 字符串用于URL参数, 请求体和JSON响应.
+// Codec between a key or value and a string. The string is used in URL parameters, request bodies and JSON responses.
+type Codec[T any] interface {
+	Encode(v T) (string, error)
+	Decode(s string) (T, error)
+}
+
+// StringCodec 原样使用字符串
+// Use strings as they are
+type StringCodec struct{}
+
+func (StringCodec) Encode(v string) (string, error) { return v, nil }
+
+func (StringCodec) Decode(s string) (string, error) { return s, nil }
+
+// BytesCodec 将字节切片作为字符串
+// Treat byte slices as strings
+type BytesCodec struct{}
+
+func (BytesCodec) Encode(v []byte) (string, error) { return string(v), nil }
+
+func (BytesCodec) Decode(s string) ([]byte, error) { return []byte(s), nil }
+
+// IntCodec 十进制整数
+// Decimal integers
+type IntCodec struct{}
+
+func (IntCodec) Encode(v int) (string, error) { return strconv.Itoa(v), nil }
+
+func (IntCodec) Decode(s string) (int, error) { return strconv.Atoi(s) }
+
+// JSONCodec 使用 encoding/json 的编解码器
+// Codec based on encoding/json
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Encode(v T) (string, error) {
+	p, err := json.Marshal(v)
+	return string(p), err
+}
+
+func (JSONCodec[T]) Decode(s string) (v T, err error) {
+	err = json.Unmarshal([]byte(s), &v)
+	return v, err
+}
+
+// 默认的编解码器: string, []byte 和 int 使用对应的文本格式, 其他类型使用JSON
+func defaultCodec[T any]() Codec[T] {
+	var v T
+	switch any(v).(type) {
+	case string:
+		return any(StringCodec{}).(Codec[T])
+	case []byte:
+		return any(BytesCodec{}).(Codec[T])
+	case int:
+		return any(IntCodec{}).(Codec[T])
+	default:
+		return JSONCodec[T]{}
+	}
+}
@@ -0,0 +1,552 @@
+package memorycache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FsyncPolicy 追加日志的刷盘策略
+// Fsync policy of the append-only log
+type FsyncPolicy uint8
+
+const (
+	FsyncEverySecond FsyncPolicy = iota // 每条记录写入操作系统, 每秒刷盘一次
+	FsyncAlways                         // 每条记录写入后立即刷盘
+	FsyncNo                             // 每秒写入操作系统一次, 由操作系统决定何时刷盘
+)
+
+const (
+	aofMagic   = "MCAO"
+	aofVersion = 1
+
+	aofSet    = 1
+	aofExpire = 2
+	aofDelete = 3
+	aofClear  = 4
+)
+
+var ErrAOFFormat = errors.New("memorycache: invalid append-only log")
+
+// 追加日志. 每条记录的格式为: 长度, 内容, CRC32校验和.
+// 写入方法在存储桶的锁内调用, 因此同一个键的记录顺序与内存中的操作顺序一致.
+type appendLog[K comparable, V any] struct {
+	sync.Mutex
+	codec       Codec[K, V]
+	path        string
+	policy      FsyncPolicy
+	rewriteSize int64
+
+	file       *os.File
+	w          *bufio.Writer
+	size, base int64 // 当前大小和上次重写后的大小
+	dirty      bool  // 是否有尚未刷盘的数据
+	err        error // 第一个持久化错误, 出错后不再写入
+	buf, frame []byte
+
+	// 重写期间的新记录同时写入pending, 重写完成后追加到新日志
+	rewriteMu sync.Mutex
+	rewriting bool
+	pending   []byte
+}
+
+func (c *appendLog[K, V]) set(ele *Element[K, V]) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	if c.file == nil || c.err != nil {
+		return
+	}
+	payload, err := c.encodeSet(c.buf[:0], ele.Key, ele.Value, ele.ExpireAt, ele.StaleAt)
+	c.buf = payload
+	if err != nil {
+		c.err = err
+		return
+	}
+	c.write(payload)
+}
+
+func (c *appendLog[K, V]) expire(key K, expireAt int64) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	if c.file == nil || c.err != nil {
+		return
+	}
+	payload, err := c.encodeKey(append(c.buf[:0], aofExpire), key)
+	if err != nil {
+		c.err = err
+		return
+	}
+	c.buf = binary.AppendVarint(payload, expireAt)
+	c.write(c.buf)
+}
+
+func (c *appendLog[K, V]) delete(key K) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	if c.file == nil || c.err != nil {
+		return
+	}
+	payload, err := c.encodeKey(append(c.buf[:0], aofDelete), key)
+	c.buf = payload
+	if err != nil {
+		c.err = err
+		return
+	}
+	c.write(payload)
+}
+
+func (c *appendLog[K, V]) clear() {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	if c.file == nil || c.err != nil {
+		return
+	}
+	c.write([]byte{aofClear})
+}
+
+func (c *appendLog[K, V]) encodeKey(p []byte, key K) ([]byte, error) {
+	data, err := c.codec.EncodeKey(key)
+	if err != nil {
+		return p, err
+	}
+	p = binary.AppendUvarint(p, uint64(len(data)))
+	return append(p, data...), nil
+}
+
+func (c *appendLog[K, V]) encodeSet(p []byte, key K, value V, expireAt, staleAt int64) ([]byte, error) {
+	p, err := c.encodeKey(append(p, aofSet), key)
+	if err != nil {
+		return p, err
+	}
+	data, err := c.codec.EncodeValue(value)
+	if err != nil {
+		return p, err
+	}
+	p = binary.AppendUvarint(p, uint64(len(data)))
+	p = append(p, data...)
+	p = binary.AppendVarint(p, expireAt)
+	return binary.AppendVarint(p, staleAt), nil
+}
+
+// 写入一条记录, 调用方持有锁
+func (c *appendLog[K, V]) write(payload []byte) {
+	c.frame = appendFrame(c.frame[:0], payload)
+	if _, err := c.w.Write(c.frame); err != nil {
+		c.err = err
+		return
+	}
+	c.size += int64(len(c.frame))
+	c.dirty = true
+	if c.rewriting {
+		c.pending = append(c.pending, c.frame...)
+	}
+
+	switch c.policy {
+	case FsyncAlways:
+		c.err = c.flush(true)
+	case FsyncEverySecond:
+		c.err = c.flush(false)
+	}
+}
+
+// 将缓冲区写入操作系统, fsync为true时同时刷盘. 调用方持有锁.
+func (c *appendLog[K, V]) flush(fsync bool) error {
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	if fsync && c.dirty {
+		c.dirty = false
+		return c.file.Sync()
+	}
+	return nil
+}
+
+// 每秒调用一次, 按刷盘策略写入和刷盘, 并返回是否需要重写
+func (c *appendLog[K, V]) tick() bool {
+	if c == nil {
+		return false
+	}
+	c.Lock()
+	defer c.Unlock()
+	if c.file == nil || c.err != nil {
+		return false
+	}
+	if err := c.flush(c.policy == FsyncEverySecond); err != nil {
+		c.err = err
+		return false
+	}
+	return !c.rewriting && c.size >= c.rewriteSize && c.size >= 2*c.base
+}
+
+func (c *appendLog[K, V]) close() {
+	if c == nil {
+		return
+	}
+	c.rewriteMu.Lock()
+	defer c.rewriteMu.Unlock()
+	c.Lock()
+	defer c.Unlock()
+	if c.file == nil {
+		return
+	}
+	if c.err == nil {
+		c.err = c.flush(c.policy != FsyncNo)
+	}
+	if err := c.file.Close(); err != nil && c.err == nil {
+		c.err = err
+	}
+	c.file = nil
+}
+
+func (c *appendLog[K, V]) error() error {
+	if c == nil {
+		return nil
+	}
+	c.Lock()
+	defer c.Unlock()
+	return c.err
+}
+
+func appendFrame(p []byte, payload []byte) []byte {
+	p = binary.AppendUvarint(p, uint64(len(payload)))
+	p = append(p, payload...)
+	return binary.BigEndian.AppendUint32(p, crc32.ChecksumIEEE(payload))
+}
+
+// Err 返回持久化错误: 追加日志的第一个错误, 或者最近一次自动快照的错误. 缓存本身不受影响.
+// Return the persistence error: the first error of the append-only log, or the error of the latest automatic snapshot.
+// The cache itself is not affected.
+func (c *MemoryCache[K, V]) Err() error {
+	if err := c.aof.error(); err != nil {
+		return err
+	}
+	if err := c.snapshotErr.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+// RewriteAOF 根据当前数据重写追加日志. 重写期间的写入不会被阻塞.
+// Rewrite the append-only log from the current data. Writes are not blocked during the rewrite.
+func (c *MemoryCache[K, V]) RewriteAOF() error {
+	if c.aof == nil {
+		return nil
+	}
+	c.aof.rewriteMu.Lock()
+	defer c.aof.rewriteMu.Unlock()
+	return c.rewriteAOF()
+}
+
+// 重写追加日志, 调用方持有rewriteMu
+func (c *MemoryCache[K, V]) rewriteAOF() error {
+	var a = c.aof
+	a.Lock()
+	if a.file == nil {
+		a.Unlock()
+		return os.ErrClosed
+	}
+	if a.err != nil {
+		a.Unlock()
+		return a.err
+	}
+	a.rewriting, a.pending = true, a.pending[:0]
+	a.Unlock()
+
+	var tmp = a.path + ".rewrite"
+	file, size, err := c.writeRewrite(tmp)
+	if err == nil {
+		err = c.finishRewrite(tmp, file, size)
+	}
+	if err != nil {
+		if file != nil {
+			_ = file.Close()
+		}
+		_ = os.Remove(tmp)
+		a.Lock()
+		a.rewriting, a.pending = false, nil
+		a.Unlock()
+	}
+	return err
+}
+
+// 将所有未过期的元素写入临时文件
+func (c *MemoryCache[K, V]) writeRewrite(path string) (*os.File, int64, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var w = bufio.NewWriter(file)
+	var size, _ = w.WriteString(aofMagic)
+	_ = w.WriteByte(aofVersion)
+	size++
+
+	var buf, frame []byte
+	var entries []snapshotEntry[K, V]
+	for _, b := range c.storage {
+		entries = c.collect(b, entries[:0])
+		for _, item := range entries {
+			if buf, err = c.aof.encodeSet(buf[:0], item.Key, item.Value, item.ExpireAt, item.StaleAt); err != nil {
+				return file, 0, err
+			}
+			frame = appendFrame(frame[:0], buf)
+			if _, err = w.Write(frame); err != nil {
+				return file, 0, err
+			}
+			size += len(frame)
+		}
+	}
+	return file, int64(size), w.Flush()
+}
+
+// 追加重写期间的新记录, 然后用临时文件替换日志
+func (c *MemoryCache[K, V]) finishRewrite(tmp string, file *os.File, size int64) error {
+	var a = c.aof
+	a.Lock()
+	defer a.Unlock()
+
+	if err := a.flush(false); err != nil {
+		return err
+	}
+	if _, err := file.Write(a.pending); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, a.path); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(a.path))
+
+	_ = a.file.Close()
+	a.file, a.w = file, bufio.NewWriter(file)
+	a.size = size + int64(len(a.pending))
+	a.base = a.size
+	a.rewriting, a.pending, a.dirty = false, nil, false
+	return nil
+}
+
+// 刷新目录项, 使重命名在崩溃后仍然有效
+func syncDir(dir string) {
+	if f, err := os.Open(dir); err == nil {
+		_ = f.Sync()
+		_ = f.Close()
+	}
+}
+
+// 打开追加日志并重放. 出错时返回的日志只记录错误, 不再写入.
+func (c *MemoryCache[K, V]) openAOF() *appendLog[K, V] {
+	var a = &appendLog[K, V]{
+		codec:       c.codec(),
+		path:        c.conf.AOFPath,
+		policy:      c.conf.AOFFsync,
+		rewriteSize: c.conf.AOFRewriteSize,
+	}
+
+	file, err := os.OpenFile(a.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		a.err = err
+		return a
+	}
+
+	offset, err := c.replay(file, a.codec)
+	if err == nil {
+		err = c.resume(file, offset)
+	}
+	if err != nil {
+		_ = file.Close()
+		a.err = err
+		return a
+	}
+
+	a.file, a.w = file, bufio.NewWriter(file)
+	a.size, a.base = offset, offset
+	if offset == 0 {
+		_, _ = a.w.WriteString(aofMagic)
+		_ = a.w.WriteByte(aofVersion)
+		a.dirty = true
+		a.err = a.flush(true)
+		a.size, a.base = int64(len(aofMagic)+1), int64(len(aofMagic)+1)
+	}
+	return a
+}
+
+// 截断不完整的尾部记录, 并将写入位置移动到末尾
+func (c *MemoryCache[K, V]) resume(file *os.File, offset int64) error {
+	if err := file.Truncate(offset); err != nil {
+		return err
+	}
+	_, err := file.Seek(offset, io.SeekStart)
+	return err
+}
+
+// 重放追加日志, 返回最后一条完整记录的结束位置. 文件头不完整时返回0.
+// 只有延伸到文件末尾的损坏记录才被视为写入中断, 由 resume 截断; 其后还有数据时返回 ErrAOFFormat, 不修改文件.
+func (c *MemoryCache[K, V]) replay(file *os.File, codec Codec[K, V]) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	var size = info.Size()
+	var r = &countingReader{r: bufio.NewReader(file)}
+	var header = make([]byte, len(aofMagic)+1)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if string(header[:len(aofMagic)]) != aofMagic || header[len(aofMagic)] != aofVersion {
+		return 0, ErrAOFFormat
+	}
+
+	var offset = r.n
+	var payload []byte
+	var sum [4]byte
+	for {
+		n, err := binary.ReadUvarint(r)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return offset, nil
+		}
+		if err != nil {
+			return offset, ErrAOFFormat
+		}
+
+		// 记录的长度超出文件末尾, 是写入中断留下的尾部
+		if rest := uint64(size - r.n); rest < 4 || n > rest-4 {
+			return offset, nil
+		}
+		if n > 2*snapshotMaxField {
+			return offset, ErrAOFFormat
+		}
+		if uint64(cap(payload)) < n {
+			payload = make([]byte, n)
+		}
+		payload = payload[:n]
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return offset, err
+		}
+		if _, err := io.ReadFull(r, sum[:]); err != nil {
+			return offset, err
+		}
+		if binary.BigEndian.Uint32(sum[:]) != crc32.ChecksumIEEE(payload) {
+			if r.n == size {
+				return offset, nil
+			}
+			return offset, ErrAOFFormat
+		}
+		if err := c.apply(payload, codec); err != nil {
+			return offset, err
+		}
+		offset = r.n
+	}
+}
+
+// 执行一条日志记录
+func (c *MemoryCache[K, V]) apply(payload []byte, codec Codec[K, V]) error {
+	if len(payload) == 0 {
+		return ErrAOFFormat
+	}
+	var r = bytes.NewReader(payload[1:])
+	var op = payload[0]
+	if op == aofClear {
+		c.Clear()
+		return nil
+	}
+
+	key, err := readKey(r, codec)
+	if err != nil {
+		return err
+	}
+	var now = c.getTimestamp()
+	switch op {
+	case aofSet:
+		data, err := readField(r)
+		if err != nil {
+			return err
+		}
+		value, err := codec.DecodeValue(data)
+		if err != nil {
+			return err
+		}
+		expireAt, err1 := binary.ReadVarint(r)
+		staleAt, err2 := binary.ReadVarint(r)
+		if err1 != nil || err2 != nil {
+			return ErrAOFFormat
+		}
+		if expireAt <= now {
+			c.Delete(key)
+			return nil
+		}
+		c.set(key, value, remaining(staleAt, now), remaining(expireAt, now), c.callback)
+	case aofExpire:
+		expireAt, err := binary.ReadVarint(r)
+		if err != nil {
+			return ErrAOFFormat
+		}
+		c.expire(key, expireAt, math.MaxInt64)
+	case aofDelete:
+		c.Delete(key)
+	default:
+		return ErrAOFFormat
+	}
+	return nil
+}
+
+func readKey[K comparable, V any](r *bytes.Reader, codec Codec[K, V]) (key K, err error) {
+	data, err := readField(r)
+	if err != nil {
+		return key, err
+	}
+	return codec.DecodeKey(data)
+}
+
+func readField(r *bytes.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil || n > uint64(r.Len()) {
+		return nil, ErrAOFFormat
+	}
+	var p = make([]byte, n)
+	_, _ = r.Read(p)
+	return p, nil
+}
+
+// 记录已读取的字节数
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
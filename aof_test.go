@@ -0,0 +1,245 @@
+package memorycache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCache_AOF(t *testing.T) {
+	t.Run("replay", func(t *testing.T) {
+		var path = filepath.Join(t.TempDir(), "cache.aof")
+		var mc = New[string, int](WithAOF(path, FsyncEverySecond), WithStats(true))
+		mc.Set("a", 1, time.Hour)
+		mc.Set("b", 2, 0)
+		mc.Set("a", 3, time.Hour)
+		mc.Set("c", 4, time.Minute)
+		mc.Delete("b")
+		mc.GetWithTTL("c", 2*time.Hour)
+		mc.SetWithStale("d", 5, time.Minute, time.Hour)
+		var src = mc.getBucket("c")
+		var expireAt = src.Find(src.hashcode, "c").ExpireAt
+		mc.Stop()
+		assert.NoError(t, mc.Err())
+
+		var mc2 = New[string, int](WithAOF(path, FsyncEverySecond), WithStats(true))
+		defer mc2.Stop()
+		assert.NoError(t, mc2.Err())
+		assert.Equal(t, mc2.Len(), 3)
+		assert.Equal(t, mc2.Stats(), Stats{})
+
+		v, ok := mc2.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, v, 3)
+		_, ok = mc2.Get("b")
+		assert.False(t, ok)
+
+		var b = mc2.getBucket("c")
+		assert.Equal(t, b.Find(b.hashcode, "c").ExpireAt, expireAt)
+		b = mc2.getBucket("d")
+		var ele = b.Find(b.hashcode, "d")
+		assert.True(t, ele.StaleAt < ele.ExpireAt)
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		var path = filepath.Join(t.TempDir(), "cache.aof")
+		var mc = New[string, int](WithAOF(path, FsyncEverySecond))
+		mc.Set("a", 1, time.Minute)
+		mc.Set("b", 2, time.Minute)
+		mc.Set("c", 3, time.Minute)
+		var deadline = time.Now().Add(time.Hour)
+		mc.ExpireAt("a", deadline)
+		mc.Persist("b")
+		mc.ExpireAt("c", time.Now().Add(-time.Second))
+		mc.Stop()
+
+		var mc2 = New[string, int](WithAOF(path, FsyncEverySecond))
+		defer mc2.Stop()
+		assert.Equal(t, mc2.Len(), 2)
+		var b = mc2.getBucket("a")
+		assert.Equal(t, b.Find(b.hashcode, "a").ExpireAt, deadline.UnixMilli())
+		d, ok := mc2.TTL("b")
+		assert.True(t, ok)
+		assert.Equal(t, d, NoExpiration)
+	})
+
+	t.Run("expiry and clear", func(t *testing.T) {
+		var path = filepath.Join(t.TempDir(), "cache.aof")
+		var mc = New[string, int](WithAOF(path, FsyncAlways), WithCachedTime(false))
+		mc.Set("a", 1, 10*time.Millisecond)
+		mc.Set("b", 1, time.Hour)
+		time.Sleep(20 * time.Millisecond)
+		_, ok := mc.Get("a")
+		assert.False(t, ok)
+		mc.Stop()
+
+		var mc2 = New[string, int](WithAOF(path, FsyncAlways))
+		assert.Equal(t, mc2.Len(), 1)
+		mc2.Clear()
+		mc2.Set("c", 1, time.Hour)
+		mc2.Stop()
+
+		var mc3 = New[string, int](WithAOF(path, FsyncAlways))
+		defer mc3.Stop()
+		_, ok = mc3.Get("b")
+		assert.False(t, ok)
+		_, ok = mc3.Get("c")
+		assert.True(t, ok)
+	})
+
+	t.Run("fsync always", func(t *testing.T) {
+		var path = filepath.Join(t.TempDir(), "cache.aof")
+		var mc = New[string, int](WithAOF(path, FsyncAlways))
+		defer mc.Stop()
+		info0, _ := os.Stat(path)
+		mc.Set("a", 1, time.Hour)
+		info1, _ := os.Stat(path)
+		assert.Greater(t, info1.Size(), info0.Size())
+	})
+
+	t.Run("torn tail", func(t *testing.T) {
+		var path = filepath.Join(t.TempDir(), "cache.aof")
+		var mc = New[string, int](WithAOF(path, FsyncNo))
+		mc.Set("a", 1, time.Hour)
+		mc.Set("b", 2, time.Hour)
+		mc.Stop()
+
+		info, _ := os.Stat(path)
+		var size = info.Size()
+		file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+		_, _ = file.Write([]byte{20, aofSet, 1})
+		_ = file.Close()
+
+		var mc2 = New[string, int](WithAOF(path, FsyncNo))
+		assert.NoError(t, mc2.Err())
+		assert.Equal(t, mc2.Len(), 2)
+		info, _ = os.Stat(path)
+		assert.Equal(t, info.Size(), size)
+		mc2.Set("c", 3, time.Hour)
+		mc2.Stop()
+
+		var mc3 = New[string, int](WithAOF(path, FsyncNo))
+		defer mc3.Stop()
+		assert.Equal(t, mc3.Len(), 3)
+	})
+
+	t.Run("corrupt tail", func(t *testing.T) {
+		var path = filepath.Join(t.TempDir(), "cache.aof")
+		var mc = New[string, int](WithAOF(path, FsyncNo))
+		mc.Set("a", 1, time.Hour)
+		mc.Stop()
+
+		info, _ := os.Stat(path)
+		var size = info.Size()
+		var mc1 = New[string, int](WithAOF(path, FsyncNo))
+		mc1.Set("b", 2, time.Hour)
+		mc1.Stop()
+
+		// 最后一条记录的校验和损坏
+		data, _ := os.ReadFile(path)
+		data[len(data)-1] ^= 0xFF
+		_ = os.WriteFile(path, data, 0644)
+
+		var mc2 = New[string, int](WithAOF(path, FsyncNo))
+		defer mc2.Stop()
+		assert.NoError(t, mc2.Err())
+		assert.ElementsMatch(t, getKeys(mc2), []string{"a"})
+		info, _ = os.Stat(path)
+		assert.Equal(t, info.Size(), size)
+	})
+
+	t.Run("corrupt middle", func(t *testing.T) {
+		var path = filepath.Join(t.TempDir(), "cache.aof")
+		var mc = New[string, int](WithAOF(path, FsyncNo))
+		mc.Set("a", 1, time.Hour)
+		mc.Stop()
+
+		info, _ := os.Stat(path)
+		var size = info.Size()
+		var mc1 = New[string, int](WithAOF(path, FsyncNo))
+		mc1.Set("b", 2, time.Hour)
+		mc1.Set("c", 3, time.Hour)
+		mc1.Stop()
+
+		// 第二条记录的载荷损坏, 之后的记录仍然完整
+		data, _ := os.ReadFile(path)
+		data[size+1] ^= 0xFF
+		_ = os.WriteFile(path, data, 0644)
+
+		var mc2 = New[string, int](WithAOF(path, FsyncNo))
+		defer mc2.Stop()
+		assert.ErrorIs(t, mc2.Err(), ErrAOFFormat)
+		after, _ := os.ReadFile(path)
+		assert.Equal(t, after, data)
+	})
+
+	t.Run("rewrite", func(t *testing.T) {
+		var path = filepath.Join(t.TempDir(), "cache.aof")
+		var mc = New[string, int](WithAOF(path, FsyncEverySecond), WithBucketNum(2))
+		for i := 0; i < 1000; i++ {
+			mc.Set("a", i, time.Hour)
+		}
+		mc.Set("b", 1, 0)
+		info0, _ := os.Stat(path)
+		assert.NoError(t, mc.RewriteAOF())
+		info1, _ := os.Stat(path)
+		assert.Less(t, info1.Size(), info0.Size()/10)
+		mc.Set("c", 1, time.Hour)
+		mc.Stop()
+		assert.Error(t, mc.RewriteAOF())
+
+		var mc2 = New[string, int](WithAOF(path, FsyncEverySecond))
+		defer mc2.Stop()
+		assert.Equal(t, mc2.Len(), 3)
+		v, _ := mc2.Get("a")
+		assert.Equal(t, v, 999)
+		_, err := os.Stat(path + ".rewrite")
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("background rewrite", func(t *testing.T) {
+		var path = filepath.Join(t.TempDir(), "cache.aof")
+		var mc = New[string, int](WithAOF(path, FsyncEverySecond), WithAOFRewriteSize(1024))
+		defer mc.Stop()
+		for i := 0; i < 1000; i++ {
+			mc.Set("a", i, time.Hour)
+		}
+		assert.Eventually(t, func() bool {
+			info, _ := os.Stat(path)
+			return info.Size() < 1024
+		}, 3*time.Second, 100*time.Millisecond)
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		var path = filepath.Join(t.TempDir(), "cache.aof")
+		_ = os.WriteFile(path, []byte("hello world"), 0644)
+		var mc = New[string, int](WithAOF(path, FsyncEverySecond))
+		defer mc.Stop()
+		assert.ErrorIs(t, mc.Err(), ErrAOFFormat)
+		mc.Set("a", 1, time.Hour)
+		assert.Equal(t, mc.Len(), 1)
+		data, _ := os.ReadFile(path)
+		assert.Equal(t, string(data), "hello world")
+	})
+
+	t.Run("codec error", func(t *testing.T) {
+		var path = filepath.Join(t.TempDir(), "cache.aof")
+		var mc = New[string, int](WithAOF(path, FsyncEverySecond), WithCodec[string, int](failingCodec{}))
+		defer mc.Stop()
+		mc.Delete("a")
+		assert.NoError(t, mc.Err())
+		mc.Set("a", 1, time.Hour)
+		assert.Error(t, mc.Err())
+		assert.Equal(t, mc.Len(), 1)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		var mc = New[string, int]()
+		defer mc.Stop()
+		assert.NoError(t, mc.Err())
+		assert.NoError(t, mc.RewriteAOF())
+	})
+}
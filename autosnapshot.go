@@ -0,0 +1,87 @@
+package memorycache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	snapshotPattern = "snapshot-*.mcs"
+	snapshotTemp    = "snapshot-*.tmp"
+)
+
+// 获取编解码器, 未设置时使用 JSONCodec
+func (c *MemoryCache[K, V]) codec() Codec[K, V] {
+	if codec, ok := optionOf[Codec[K, V]]("Codec", c.conf.Codec); ok {
+		return codec
+	}
+	return JSONCodec[K, V]{}
+}
+
+// 自动快照文件列表, 从新到旧排列
+func (c *MemoryCache[K, V]) listSnapshots() []string {
+	names, _ := filepath.Glob(filepath.Join(c.conf.SnapshotDir, snapshotPattern))
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	return names
+}
+
+// 加载最新的有效快照, 并清理上次未完成的临时文件
+func (c *MemoryCache[K, V]) loadSnapshot() {
+	if tmps, _ := filepath.Glob(filepath.Join(c.conf.SnapshotDir, snapshotTemp)); len(tmps) > 0 {
+		for _, name := range tmps {
+			_ = os.Remove(name)
+		}
+	}
+
+	for _, name := range c.listSnapshots() {
+		file, err := os.Open(name)
+		if err != nil {
+			continue
+		}
+		err = c.LoadFrom(file, c.codec())
+		_ = file.Close()
+		if err == nil {
+			return
+		}
+	}
+}
+
+// 写入快照并删除多余的旧快照. 快照逐个存储桶生成, 不会同时锁定整个缓存.
+func (c *MemoryCache[K, V]) writeSnapshot(now time.Time) error {
+	var dir = c.conf.SnapshotDir
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(dir, snapshotTemp)
+	if err != nil {
+		return err
+	}
+	err = c.SaveTo(file, c.codec())
+	if err == nil {
+		err = file.Sync()
+	}
+	if err1 := file.Close(); err == nil {
+		err = err1
+	}
+	if err == nil {
+		// 文件名包含纳秒时间戳, 按字典序排列即按时间排列
+		var name = filepath.Join(dir, fmt.Sprintf("snapshot-%020d.mcs", now.UnixNano()))
+		err = os.Rename(file.Name(), name)
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return err
+	}
+	syncDir(dir)
+
+	for i, name := range c.listSnapshots() {
+		if i >= c.conf.SnapshotRetain {
+			_ = os.Remove(name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package memorycache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCache_AutoSnapshot(t *testing.T) {
+	t.Run("rotate and load", func(t *testing.T) {
+		var dir = t.TempDir()
+		var mc = New[string, int](WithAutoSnapshot(dir, 20*time.Millisecond, 2))
+		mc.Set("a", 1, time.Hour)
+		mc.Set("b", 2, 0)
+		time.Sleep(200 * time.Millisecond)
+		mc.Stop()
+		assert.NoError(t, mc.Err())
+
+		var names = mc.listSnapshots()
+		assert.Equal(t, len(names), 2)
+		assert.True(t, names[0] > names[1])
+
+		var mc2 = New[string, int](WithAutoSnapshot(dir, time.Hour, 2), WithStats(true))
+		defer mc2.Stop()
+		assert.Equal(t, mc2.Len(), 2)
+		assert.Equal(t, mc2.Stats(), Stats{})
+		v, _ := mc2.Get("b")
+		assert.Equal(t, v, 2)
+	})
+
+	t.Run("skip invalid", func(t *testing.T) {
+		var dir = t.TempDir()
+		var mc = New[string, int](WithAutoSnapshot(dir, time.Hour, 3))
+		defer mc.Stop()
+		mc.Set("a", 1, time.Hour)
+		assert.NoError(t, mc.writeSnapshot(time.Unix(1, 0)))
+		mc.Set("b", 1, time.Hour)
+		assert.NoError(t, mc.writeSnapshot(time.Unix(2, 0)))
+		var names = mc.listSnapshots()
+		assert.NoError(t, os.WriteFile(names[0], []byte("broken"), 0644))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "snapshot-1.tmp"), nil, 0644))
+
+		var mc2 = New[string, int](WithAutoSnapshot(dir, time.Hour, 3))
+		defer mc2.Stop()
+		assert.Equal(t, mc2.Len(), 1)
+		_, ok := mc2.Get("a")
+		assert.True(t, ok)
+		tmps, _ := filepath.Glob(filepath.Join(dir, snapshotTemp))
+		assert.Empty(t, tmps)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		var path = filepath.Join(t.TempDir(), "file")
+		assert.NoError(t, os.WriteFile(path, nil, 0644))
+		var mc = New[string, int](WithAutoSnapshot(path, 10*time.Millisecond, 1))
+		defer mc.Stop()
+		assert.Eventually(t, func() bool { return mc.Err() != nil }, time.Second, 10*time.Millisecond)
+	})
+}
@@ -0,0 +1,92 @@
+package memorycache
+
+import (
+	"sort"
+	"time"
+)
+
+// 批量操作中的一个键, 高32位为存储桶序号, 低32位为键在参数中的位置.
+// 排序后同一个存储桶的键相邻, 且保持参数中的顺序.
+type batchItem uint64
+
+func (c batchItem) slot() uint64 { return uint64(c) >> 32 }
+
+func (c batchItem) index() int { return int(uint32(c)) }
+
+type batchItems []batchItem
+
+func (c batchItems) Len() int { return len(c) }
+
+func (c batchItems) Less(i, j int) bool { return c[i] < c[j] }
+
+func (c batchItems) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+
+// 按存储桶分组, 返回排序后的键和每个键的哈希
+func (c *MemoryCache[K, V]) groupByBucket(n int, key func(i int) K) (batchItems, []uint64) {
+	var items = make(batchItems, n)
+	var hashes = make([]uint64, n)
+	var mask = uint64(c.conf.BucketNum - 1)
+	for i := 0; i < n; i++ {
+		hashes[i] = c.hasher.Hash(key(i))
+		items[i] = batchItem((hashes[i]&mask)<<32 | uint64(i))
+	}
+	sort.Sort(items)
+	return items, hashes
+}
+
+// 依次处理每个存储桶中的键, 每个存储桶只加锁一次. f的参数为键在参数中的位置.
+func (c *MemoryCache[K, V]) batch(n int, key func(i int) K, f func(b bucketWrapper[K, V], index int)) {
+	var items, hashes = c.groupByBucket(n, key)
+	for i := 0; i < len(items); {
+		var j = i
+		for j < len(items) && items[j].slot() == items[i].slot() {
+			j++
+		}
+		func(group batchItems) {
+			var b = c.storage[group[0].slot()]
+			b.Lock()
+			defer b.Unlock()
+			for _, item := range group {
+				var index = item.index()
+				f(bucketWrapper[K, V]{bucket: b, hashcode: hashes[index]}, index)
+			}
+		}(items[i:j])
+		i = j
+	}
+}
+
+// GetMany 批量查询, 返回存在的键值. 按存储桶分组, 每个存储桶只加锁一次.
+// Query in batch and return the existing key-value pairs. Keys are grouped by bucket and each bucket is locked only once.
+func (c *MemoryCache[K, V]) GetMany(keys []K) map[K]V {
+	var result = make(map[K]V, len(keys))
+	c.batch(len(keys), func(i int) K { return keys[i] }, func(b bucketWrapper[K, V], index int) {
+		var key = keys[index]
+		if v, ok := c.doGet(b, key, true); ok {
+			result[key] = v
+		}
+	})
+	return result
+}
+
+// SetMany 批量写入, 所有键使用相同的过期时间, ttl<=0表示永不过期. 重复的键以最后一个为准.
+// 按存储桶分组, 每个存储桶只加锁一次.
+// Write in batch with the same expiration time for all keys, ttl<=0 means never expire. The last of duplicate keys wins.
+// Keys are grouped by bucket and each bucket is locked only once.
+func (c *MemoryCache[K, V]) SetMany(entries []Entry[K, V], ttl time.Duration) {
+	c.batch(len(entries), func(i int) K { return entries[i].Key }, func(b bucketWrapper[K, V], index int) {
+		var entry = entries[index]
+		c.doSet(b, entry.Key, entry.Value, ttl, ttl, c.callback)
+	})
+}
+
+// DeleteMany 批量删除, 返回删除的数量. 按存储桶分组, 每个存储桶只加锁一次.
+// Delete in batch and return the number of deleted keys. Keys are grouped by bucket and each bucket is locked only once.
+func (c *MemoryCache[K, V]) DeleteMany(keys []K) int {
+	var n = 0
+	c.batch(len(keys), func(i int) K { return keys[i] }, func(b bucketWrapper[K, V], index int) {
+		if c.doDelete(b, keys[index]) {
+			n++
+		}
+	})
+	return n
+}
@@ -0,0 +1,126 @@
+package memorycache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCache_GetMany(t *testing.T) {
+	t.Run("", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(4), WithStats(true))
+		defer mc.Stop()
+		var keys []string
+		for i := 0; i < 100; i++ {
+			var key = strconv.Itoa(i)
+			keys = append(keys, key)
+			if i%2 == 0 {
+				mc.Set(key, i, 0)
+			}
+		}
+
+		var result = mc.GetMany(keys)
+		assert.Equal(t, len(result), 50)
+		for k, v := range result {
+			assert.Equal(t, strconv.Itoa(v), k)
+		}
+		assert.Equal(t, mc.Stats().Hits, uint64(50))
+		assert.Equal(t, mc.Stats().Misses, uint64(50))
+		assert.Equal(t, len(mc.GetMany(nil)), 0)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		var mc = New[string, int](WithCachedTime(false))
+		defer mc.Stop()
+		mc.Set("a", 1, time.Millisecond)
+		mc.Set("b", 2, 0)
+		time.Sleep(5 * time.Millisecond)
+		assert.Equal(t, mc.GetMany([]string{"a", "b", "b"}), map[string]int{"b": 2})
+		assert.Equal(t, mc.Len(), 1)
+	})
+}
+
+func TestMemoryCache_SetMany(t *testing.T) {
+	t.Run("", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(8), WithCachedTime(false))
+		defer mc.Stop()
+		var entries []Entry[string, int]
+		for i := 0; i < 100; i++ {
+			entries = append(entries, Entry[string, int]{Key: strconv.Itoa(i), Value: i})
+		}
+		entries = append(entries, Entry[string, int]{Key: "0", Value: -1})
+		mc.SetMany(entries, time.Hour)
+		assert.Equal(t, mc.Len(), 100)
+
+		v, _ := mc.Get("0")
+		assert.Equal(t, v, -1)
+		v, _ = mc.Get("99")
+		assert.Equal(t, v, 99)
+		_, expireAt, _ := mc.Peek("50")
+		d := time.Until(expireAt)
+		assert.True(t, d > 59*time.Minute && d <= time.Hour)
+
+		mc.SetMany(entries[:1], 0)
+		_, expireAt, ok := mc.Peek("0")
+		assert.True(t, ok)
+		assert.True(t, expireAt.IsZero())
+	})
+
+	t.Run("callback", func(t *testing.T) {
+		var evicted []string
+		var mc = New[string, int](WithBucketNum(1), WithBucketSize(0, 2))
+		defer mc.Stop()
+		mc.SetWithCallback("x", 0, 0, func(ele *Element[string, int], reason Reason) {
+			evicted = append(evicted, ele.Key)
+		})
+		mc.SetMany([]Entry[string, int]{{Key: "a", Value: 1}, {Key: "b", Value: 2}}, 0)
+		assert.Equal(t, evicted, []string{"x"})
+		assert.Equal(t, mc.GetMany([]string{"a", "b"}), map[string]int{"a": 1, "b": 2})
+	})
+}
+
+func TestMemoryCache_DeleteMany(t *testing.T) {
+	t.Run("", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(4))
+		defer mc.Stop()
+		var keys []string
+		for i := 0; i < 100; i++ {
+			var key = strconv.Itoa(i)
+			keys = append(keys, key)
+			mc.Set(key, i, 0)
+		}
+		assert.Equal(t, mc.DeleteMany(append(keys[:30:30], "x", "0")), 30)
+		assert.Equal(t, mc.Len(), 70)
+		assert.Equal(t, mc.DeleteMany(keys), 70)
+		assert.Equal(t, mc.Len(), 0)
+		assert.Equal(t, mc.DeleteMany(nil), 0)
+	})
+}
+
+func TestMemoryCache_groupByBucket(t *testing.T) {
+	t.Run("", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(16))
+		defer mc.Stop()
+		var keys []string
+		for i := 0; i < 1000; i++ {
+			keys = append(keys, strconv.Itoa(i%300))
+		}
+		var items, hashes = mc.groupByBucket(len(keys), func(i int) string { return keys[i] })
+		assert.Equal(t, len(items), len(keys))
+
+		var seen = make(map[uint64]bool)
+		for i, item := range items {
+			var b = mc.getBucket(keys[item.index()])
+			assert.Equal(t, mc.storage[item.slot()], b.bucket)
+			assert.Equal(t, hashes[item.index()], b.hashcode)
+			if i > 0 && items[i-1].slot() == item.slot() {
+				assert.True(t, items[i-1].index() < item.index())
+				continue
+			}
+			assert.False(t, seen[item.slot()])
+			seen[item.slot()] = true
+		}
+	})
+}
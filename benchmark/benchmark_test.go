@@ -88,6 +88,27 @@ func BenchmarkMemoryCache_SetAndGet(b *testing.B) {
 	})
 }
 
+func BenchmarkMemoryCache_GetMany(b *testing.B) {
+	const batch = 100
+	var mc = memorycache.New[string, int](options...)
+	for i := 0; i < benchcount; i++ {
+		mc.Set(benchkeys[i%benchcount], 1, time.Hour)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var i = 0
+		for pb.Next() {
+			index := getIndex(i)
+			i += batch
+			if index+batch > len(benchkeys) {
+				index = 0
+			}
+			mc.GetMany(benchkeys[index : index+batch])
+		}
+	})
+}
+
 func BenchmarkRistretto_Set(b *testing.B) {
 	var mc, _ = ristretto.NewCache(&ristretto.Config{
 		NumCounters: capacity * sharding * 10, // number of keys to track frequency of (10M).
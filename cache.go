@@ -8,20 +8,37 @@ import (
 	"time"
 
 	"github.com/dolthub/maphash"
+	"github.com/lxzan/dao/algo"
 	"github.com/lxzan/memorycache/internal/containers"
+	"github.com/lxzan/memorycache/internal/singleflight"
+	"github.com/lxzan/memorycache/internal/sketch"
 	"github.com/lxzan/memorycache/internal/utils"
 )
 
 type MemoryCache[K comparable, V any] struct {
-	conf      *config
-	storage   []*bucket[K, V]
-	hasher    utils.Hasher[K]
-	timestamp atomic.Int64
-	ctx       context.Context
-	cancel    context.CancelFunc
-	wg        sync.WaitGroup
-	once      sync.Once
-	callback  CallbackFunc[*Element[K, V]]
+	conf       *config
+	storage    []*bucket[K, V]
+	hasher     utils.Hasher[K]
+	timestamp  atomic.Int64
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+	once       sync.Once
+	closeOnce  sync.Once
+	callback   CallbackFunc[*Element[K, V]]
+	group      *singleflight.Group[K, V]
+	refresher  *singleflight.Group[K, V] // 后台刷新的调用组, 不缓存加载错误
+	loader     LoaderFunc[K, V]
+	weigher    WeigherFunc[K, V]
+	aof        *appendLog[K, V]  // 追加日志, 未开启时为nil
+	dispatcher *dispatcher[K, V] // 异步回调分发器, 未开启时为nil
+	hub        *hub[K, V]        // 事件订阅中心
+
+	// 最近一次自动快照的错误
+	snapshotErr atomic.Pointer[error]
+
+	// 后台检查的次数和累计耗时(纳秒)
+	janitorRuns, janitorNanos atomic.Int64
 }
 
 // New 创建缓存数据库实例
@@ -41,29 +58,81 @@ func New[K comparable, V any](options ...Option) *MemoryCache[K, V] {
 		once:    sync.Once{},
 	}
 	mc.callback = func(entry *Element[K, V], reason Reason) {}
+	mc.group = singleflight.New[K, V](conf.LoaderErrorTTL)
+	mc.refresher = singleflight.New[K, V](0)
+	mc.loader, _ = optionOf[LoaderFunc[K, V]]("Loader", conf.Loader)
+	mc.weigher, _ = optionOf[WeigherFunc[K, V]]("Weigher", conf.Weigher)
+	optionOf[Codec[K, V]]("Codec", conf.Codec)
 	mc.ctx, mc.cancel = context.WithCancel(context.Background())
 	mc.timestamp.Store(time.Now().UnixMilli())
 
+	var global *capacity[K, V]
+	if conf.GlobalEntries > 0 || conf.GlobalCost > 0 {
+		global = &capacity[K, V]{maxEntries: int64(conf.GlobalEntries), maxCost: conf.GlobalCost, storage: mc.storage}
+	}
+	if conf.AsyncCallback {
+		mc.dispatcher = newDispatcher[K, V](conf.CallbackQueueSize, conf.CallbackOverflow)
+	}
+	mc.hub = newHub[K, V]()
 	for i, _ := range mc.storage {
-		b := (&bucket[K, V]{conf: conf}).init()
+		b := (&bucket[K, V]{conf: conf, global: global, dispatcher: mc.dispatcher, hub: mc.hub}).init()
 		mc.storage[i] = b
 	}
 
+	// 重放追加日志时不写入日志, 完成后再启用
+	var autoSnapshot = conf.SnapshotDir != "" && conf.SnapshotInterval > 0
+	if conf.AOFPath != "" {
+		mc.aof = mc.openAOF()
+		for _, b := range mc.storage {
+			b.aof = mc.aof
+		}
+		mc.ResetStats()
+	} else if autoSnapshot {
+		mc.loadSnapshot()
+		mc.ResetStats()
+	}
+
 	go func() {
 		var d0 = conf.MaxInterval
 		var ticker = time.NewTicker(d0)
 		defer ticker.Stop()
 
+		// 未开启自动快照时, 从nil通道接收会一直阻塞
+		var snapshots <-chan time.Time
+		if autoSnapshot {
+			var snapshotTicker = time.NewTicker(conf.SnapshotInterval)
+			defer snapshotTicker.Stop()
+			snapshots = snapshotTicker.C
+		}
+
 		for {
 			select {
 			case <-mc.ctx.Done():
 				mc.wg.Done()
 				return
+			case now := <-snapshots:
+				if err := mc.writeSnapshot(now); err != nil {
+					mc.snapshotErr.Store(&err)
+				} else {
+					mc.snapshotErr.Store(nil)
+				}
 			case now := <-ticker.C:
 				var sum = 0
 				for _, b := range mc.storage {
 					sum += b.Check(now.UnixMilli(), conf.DeleteLimits)
 				}
+				mc.janitorRuns.Add(1)
+				mc.janitorNanos.Add(int64(time.Since(now)))
+				if conf.LoaderErrorTTL > 0 {
+					mc.group.Purge()
+				}
+				if mc.loader != nil {
+					for _, b := range mc.storage {
+						for _, task := range b.Refreshable(now.UnixMilli(), conf.DeleteLimits) {
+							mc.refresh(task)
+						}
+					}
+				}
 
 				// 删除数量超过阈值, 缩小时间间隔
 				if sum > 0 {
@@ -89,6 +158,12 @@ func New[K comparable, V any](options ...Option) *MemoryCache[K, V] {
 				return
 			case now := <-ticker.C:
 				mc.timestamp.Store(now.UnixMilli())
+				if mc.aof.tick() && mc.aof.rewriteMu.TryLock() {
+					go func() {
+						defer mc.aof.rewriteMu.Unlock()
+						_ = mc.rewriteAOF()
+					}()
+				}
 			}
 		}
 	}()
@@ -96,17 +171,59 @@ func New[K comparable, V any](options ...Option) *MemoryCache[K, V] {
 	return mc
 }
 
-// Clear 清空缓存
-// clear caches
+// Clear 清空缓存. 每个元素都会触发回调, 原因为 ReasonCleared, 已过期但尚未删除的元素为 ReasonExpired.
+// 回调在释放所有锁之后调用.
+// clear caches. The callback of every element is triggered with ReasonCleared,
+// or ReasonExpired for elements that have expired but not yet been removed.
+// Callbacks are invoked after all locks are released.
 func (c *MemoryCache[K, V]) Clear() {
+	// 锁定所有存储桶, 保证清空记录之后的日志与内存数据一致
 	for _, b := range c.storage {
 		b.Lock()
-		b.init()
+	}
+	c.aof.clear()
+	if c.hub.enabled() {
+		c.hub.publish(Event[K, V]{Type: EventClear})
+	}
+	var now = c.getTimestamp()
+	var lists = make([]*deque[K, V], len(c.storage))
+	for i, b := range c.storage {
+		lists[i] = b.Reset()
+	}
+	for _, b := range c.storage {
 		b.Unlock()
 	}
+	for i, b := range c.storage {
+		b.Drain(lists[i], now, ReasonCleared)
+	}
 }
 
 func (c *MemoryCache[K, V]) Stop() {
+	c.halt()
+	c.release()
+}
+
+// Close 停止后台协程并删除所有元素, 每个元素都会触发回调, 原因为 ReasonClosed, 已过期的元素为 ReasonExpired.
+// 追加日志不会记录这些删除, 下次启动时仍然可以恢复数据. 回调在释放锁之后调用, 异步回调会在 Close 返回前投递完成.
+// Stop the background goroutines and remove all elements. The callback of every element is triggered with ReasonClosed,
+// or ReasonExpired for expired elements. These removals are not recorded in the append-only log, so the data can
+// still be restored on the next start. Callbacks are invoked after the locks are released,
+// and asynchronous callbacks are delivered before Close returns.
+func (c *MemoryCache[K, V]) Close() {
+	c.halt()
+	c.aof.close()
+	var now = c.getTimestamp()
+	for _, b := range c.storage {
+		b.Lock()
+		var list = b.Reset()
+		b.Unlock()
+		b.Drain(list, now, ReasonClosed)
+	}
+	c.release()
+}
+
+// 停止后台协程
+func (c *MemoryCache[K, V]) halt() {
 	c.once.Do(func() {
 		c.wg.Add(2)
 		c.cancel()
@@ -114,6 +231,15 @@ func (c *MemoryCache[K, V]) Stop() {
 	})
 }
 
+// 关闭追加日志, 回调分发器和事件订阅
+func (c *MemoryCache[K, V]) release() {
+	c.closeOnce.Do(func() {
+		c.aof.close()
+		c.dispatcher.close()
+		c.hub.close()
+	})
+}
+
 func (c *MemoryCache[K, V]) getTimestamp() int64 {
 	if c.conf.CachedTime {
 		return c.timestamp.Load()
@@ -129,6 +255,23 @@ func (c *MemoryCache[K, V]) getExp(d time.Duration) int64 {
 	return c.getTimestamp() + d.Milliseconds()
 }
 
+// 获取提前刷新时间, 未开启提前刷新或永不过期时返回math.MaxInt64
+func (c *MemoryCache[K, V]) getRefreshAt(d time.Duration) int64 {
+	if c.loader == nil || c.conf.RefreshFraction <= 0 || d <= 0 {
+		return math.MaxInt64
+	}
+	var ttl = d.Milliseconds()
+	return c.getTimestamp() + ttl - int64(float64(ttl)*c.conf.RefreshFraction)
+}
+
+// 计算键值的开销, 未设置计算函数时每个元素的开销为1
+func (c *MemoryCache[K, V]) weigh(key K, value V) int64 {
+	if c.weigher == nil {
+		return 1
+	}
+	return c.weigher(key, value)
+}
+
 func (c *MemoryCache[K, V]) getBucket(key K) bucketWrapper[K, V] {
 	var hashcode = c.hasher.Hash(key)
 	var index = hashcode & uint64(c.conf.BucketNum-1)
@@ -137,21 +280,23 @@ func (c *MemoryCache[K, V]) getBucket(key K) bucketWrapper[K, V] {
 
 // 查找数据. 如果存在且超时, 删除并返回false
 // @ele 查找结果
-// @conflict 是否哈希冲突
 // @exist 是否存在
-func (c *MemoryCache[K, V]) fetch(b bucketWrapper[K, V], key K) (ele *Element[K, V], conflict, exist bool) {
-	addr, ok := b.Map.Get(b.hashcode)
-	if !ok {
-		return nil, false, false
+func (c *MemoryCache[K, V]) fetch(b bucketWrapper[K, V], key K) (ele *Element[K, V], exist bool) {
+	if b.Sketch != nil {
+		b.Sketch.Increment(b.hashcode)
+	}
+
+	ele = b.Find(b.hashcode, key)
+	if ele == nil {
+		return nil, false
 	}
 
-	ele = b.List.Get(addr)
 	if ele.expired(c.getTimestamp()) {
 		b.Delete(ele, ReasonExpired)
-		return nil, false, false
+		return nil, false
 	}
 
-	return ele, key != ele.Key, true
+	return ele, true
 }
 
 // Set 设置键值和过期时间. exp<=0表示永不过期.
@@ -163,44 +308,123 @@ func (c *MemoryCache[K, V]) Set(key K, value V, exp time.Duration) (exist bool)
 // SetWithCallback 设置键值, 过期时间和回调函数. 容量溢出和过期都会触发回调.
 // Set the key value, expiration time and callback function. The callback is triggered by both capacity overflow and expiration.
 func (c *MemoryCache[K, V]) SetWithCallback(key K, value V, exp time.Duration, cb CallbackFunc[*Element[K, V]]) (exist bool) {
+	return c.set(key, value, exp, exp, cb)
+}
+
+// 写入键值. 值在soft之后变为陈旧数据, 在hard之后过期.
+func (c *MemoryCache[K, V]) set(key K, value V, soft, hard time.Duration, cb CallbackFunc[*Element[K, V]]) (exist bool) {
 	var b = c.getBucket(key)
 	b.Lock()
 	defer b.Unlock()
+	return c.doSet(b, key, value, soft, hard, cb)
+}
+
+// 写入键值, 调用方需持有存储桶的锁
+func (c *MemoryCache[K, V]) doSet(b bucketWrapper[K, V], key K, value V, soft, hard time.Duration, cb CallbackFunc[*Element[K, V]]) (exist bool) {
+	ele, ok := c.fetch(b, key)
+	return c.write(b, ele, ok, key, value, soft, hard, cb)
+}
 
-	var expireAt = c.getExp(exp)
-	ele, conflict, ok := c.fetch(b, key)
-	if conflict {
-		ok = false
-		b.Delete(ele, ReasonEvicted)
+// 写入键值, ele和ok为fetch的结果. 调用方需持有存储桶的锁.
+func (c *MemoryCache[K, V]) write(b bucketWrapper[K, V], ele *Element[K, V], ok bool, key K, value V, soft, hard time.Duration, cb CallbackFunc[*Element[K, V]]) (exist bool) {
+	var expireAt = c.getExp(hard)
+	var staleAt = algo.Min(c.getExp(soft), expireAt)
+	var refreshAt = c.getRefreshAt(soft)
+	if c.loader != nil && staleAt < expireAt && staleAt < refreshAt {
+		refreshAt = staleAt
 	}
+
+	var cost = c.weigh(key, value)
 	if ok {
-		ele.Value, ele.cb = value, cb
+		ele.cb, ele.refreshAt = cb, refreshAt
 		b.UpdateTTL(ele, expireAt)
+		ele.StaleAt = staleAt
+		c.replace(b, ele, value, cost)
 		return true
 	}
 
-	ele = b.GetElement()
+	if !b.Admit(b.hashcode, cost) {
+		b.stats.reject()
+		b.notify(&Element[K, V]{Key: key, Value: value, ExpireAt: expireAt, StaleAt: staleAt, cost: cost, cb: cb}, ReasonEvicted)
+		return false
+	}
+
+	ele = b.GetElement(cost)
 	ele.Key, ele.Value, ele.ExpireAt, ele.hashcode, ele.cb = key, value, expireAt, b.hashcode, cb
+	ele.StaleAt, ele.refreshAt, ele.cost = staleAt, refreshAt, cost
 	b.Insert(ele)
+	b.stats.set()
+	b.aof.set(ele)
+	if b.hub.enabled() {
+		b.hub.publish(Event[K, V]{Type: EventInsert, Key: key, NewValue: value})
+	}
 	return false
 }
 
+// 替换已存在元素的值, 不修改过期时间. 新的开销超出上限时元素被淘汰, 返回false. 调用方需持有存储桶的锁.
+func (c *MemoryCache[K, V]) replace(b bucketWrapper[K, V], ele *Element[K, V], value V, cost int64) bool {
+	var old = ele.Value
+	ele.Value = value
+	b.stamp(ele)
+	if !b.Resize(ele, cost) {
+		return false
+	}
+	b.stats.update()
+	b.aof.set(ele)
+	if b.hub.enabled() {
+		b.hub.publish(Event[K, V]{Type: EventUpdate, Key: ele.Key, OldValue: old, NewValue: value})
+	}
+	return true
+}
+
 // Get 查询缓存
 // query cache
 func (c *MemoryCache[K, V]) Get(key K) (v V, exist bool) {
+	return c.get(key, true)
+}
+
+// 查询缓存. record表示是否记录命中统计.
+func (c *MemoryCache[K, V]) get(key K, record bool) (v V, exist bool) {
 	var b = c.getBucket(key)
 	b.Lock()
 	defer b.Unlock()
+	return c.doGet(b, key, record)
+}
 
-	ele, conflict, ok := c.fetch(b, key)
-	if !ok || conflict {
+// 查询缓存, 调用方需持有存储桶的锁
+func (c *MemoryCache[K, V]) doGet(b bucketWrapper[K, V], key K, record bool) (v V, exist bool) {
+	ele, ok := c.fetch(b, key)
+	if record {
+		b.stats.hit(ok)
+	}
+	if !ok {
 		return v, false
 	}
 
-	b.List.MoveToBack(ele.addr)
+	b.Policy.Access(ele)
+	c.checkRefresh(ele)
 	return ele.Value, true
 }
 
+// Peek 查询缓存和过期时间, 永不过期时返回零值时间. 不记录统计, 不影响元素在淘汰策略中的位置和准入频率, 也不触发提前刷新.
+// Query the cache and the expiration time, which is the zero time if the element never expires.
+// Statistics, the position of the element in the eviction policy and the admission frequency are not changed,
+// and no refresh is triggered.
+func (c *MemoryCache[K, V]) Peek(key K) (v V, expireAt time.Time, exist bool) {
+	var b = c.getBucket(key)
+	b.Lock()
+	defer b.Unlock()
+
+	var ele = b.Find(b.hashcode, key)
+	if ele == nil || ele.expired(c.getTimestamp()) {
+		return v, expireAt, false
+	}
+	if ele.ExpireAt != math.MaxInt64 {
+		expireAt = time.UnixMilli(ele.ExpireAt)
+	}
+	return ele.Value, expireAt, true
+}
+
 // GetWithTTL 获取. 如果存在, 刷新过期时间.
 // Get a value. If it exists, refreshes the expiration time.
 func (c *MemoryCache[K, V]) GetWithTTL(key K, exp time.Duration) (v V, exist bool) {
@@ -208,12 +432,15 @@ func (c *MemoryCache[K, V]) GetWithTTL(key K, exp time.Duration) (v V, exist boo
 	b.Lock()
 	defer b.Unlock()
 
-	ele, conflict, ok := c.fetch(b, key)
-	if !ok || conflict {
+	ele, ok := c.fetch(b, key)
+	b.stats.hit(ok)
+	if !ok {
 		return v, false
 	}
 
+	ele.refreshAt = c.getRefreshAt(exp)
 	b.UpdateTTL(ele, c.getExp(exp))
+	b.aof.expire(key, ele.ExpireAt)
 	return ele.Value, true
 }
 
@@ -231,19 +458,31 @@ func (c *MemoryCache[K, V]) GetOrCreateWithCallback(key K, value V, exp time.Dur
 	defer b.Unlock()
 
 	expireAt := c.getExp(exp)
-	ele, conflict, ok := c.fetch(b, key)
-	if conflict {
-		ok = false
-		b.Delete(ele, ReasonEvicted)
-	}
+	ele, ok := c.fetch(b, key)
+	b.stats.hit(ok)
 	if ok {
+		ele.refreshAt = c.getRefreshAt(exp)
 		b.UpdateTTL(ele, expireAt)
+		b.aof.expire(key, expireAt)
 		return ele.Value, true
 	}
 
-	ele = b.GetElement()
+	var cost = c.weigh(key, value)
+	if !b.Admit(b.hashcode, cost) {
+		b.stats.reject()
+		b.notify(&Element[K, V]{Key: key, Value: value, ExpireAt: expireAt, StaleAt: expireAt, cost: cost, cb: cb}, ReasonEvicted)
+		return value, false
+	}
+
+	ele = b.GetElement(cost)
 	ele.Key, ele.Value, ele.ExpireAt, ele.hashcode, ele.cb = key, value, expireAt, b.hashcode, cb
+	ele.refreshAt, ele.cost = c.getRefreshAt(exp), cost
 	b.Insert(ele)
+	b.stats.set()
+	b.aof.set(ele)
+	if b.hub.enabled() {
+		b.hub.publish(Event[K, V]{Type: EventInsert, Key: key, NewValue: value})
+	}
 	return value, false
 }
 
@@ -253,9 +492,13 @@ func (c *MemoryCache[K, V]) Delete(key K) (exist bool) {
 	var b = c.getBucket(key)
 	b.Lock()
 	defer b.Unlock()
+	return c.doDelete(b, key)
+}
 
-	ele, conflict, ok := c.fetch(b, key)
-	if ok && !conflict {
+// 删除缓存, 调用方需持有存储桶的锁
+func (c *MemoryCache[K, V]) doDelete(b bucketWrapper[K, V], key K) (exist bool) {
+	ele, ok := c.fetch(b, key)
+	if ok {
 		b.Delete(ele, ReasonDeleted)
 		return true
 	}
@@ -296,13 +539,54 @@ func (c *MemoryCache[K, V]) Len() int {
 	return num
 }
 
+// Cost 获取当前缓存元素的总开销, 不做过期检查.
+// Gets the total cost of the current cached elements, without checking for expiration.
+func (c *MemoryCache[K, V]) Cost() int64 {
+	var sum int64 = 0
+	for _, b := range c.storage {
+		b.Lock()
+		sum += b.cost
+		b.Unlock()
+	}
+	return sum
+}
+
 type (
 	bucket[K comparable, V any] struct {
 		sync.Mutex
-		conf *config
-		Map  containers.Map[uint64, pointer]
-		Heap *heap[K, V]
-		List *deque[K, V]
+		conf   *config
+		Map    containers.Map[uint64, pointer] // 哈希 => 冲突链表头
+		Heap   *heap[K, V]
+		List   *deque[K, V]
+		Policy policy[K, V]
+		Sketch *sketch.Sketch // 访问频率估计, 未开启准入策略时为nil
+
+		// 提前刷新的最大时间窗口, 毫秒
+		window int64
+
+		// 当前总开销和开销上限, budget<=0表示不限制
+		cost, budget int64
+
+		// 全局容量, 未设置全局容量时为nil
+		global *capacity[K, V]
+
+		// 统计数据, 未开启统计时为nil
+		stats *counters
+
+		// 追加日志, 未开启时为nil
+		aof *appendLog[K, V]
+
+		// 异步回调分发器, 未开启时为nil, 在锁内同步调用回调
+		dispatcher *dispatcher[K, V]
+
+		// 锁内产生的异步回调通知, 释放锁之后投递
+		pending []notification[K, V]
+
+		// 写入计数, 用于生成元素的写入版本
+		writes uint64
+
+		// 事件订阅中心
+		hub *hub[K, V]
 	}
 
 	bucketWrapper[K comparable, V any] struct {
@@ -312,12 +596,50 @@ type (
 )
 
 func (c *bucket[K, V]) init() *bucket[K, V] {
+	if c.global != nil && c.List != nil {
+		c.global.add(-int64(c.List.Len()), -c.cost)
+	}
 	c.Map = containers.NewMap[uint64, pointer](c.conf.BucketSize, c.conf.SwissTable)
 	c.List = newDeque[K, V](c.conf.BucketSize)
 	c.Heap = newHeap[K, V](c.List, c.conf.BucketSize)
+	c.Policy = newPolicy[K, V](c.conf.EvictionPolicy, c.List)
+	if c.conf.Admission == AdmissionTinyLFU {
+		c.Sketch = sketch.New(c.conf.BucketCap)
+	}
+	c.window = 0
+	c.cost, c.budget = 0, 0
+	if c.conf.Stats && c.stats == nil {
+		c.stats = new(counters)
+	}
+	if c.conf.MaxCost > 0 {
+		c.budget = algo.Max(c.conf.MaxCost/int64(c.conf.BucketNum), 1)
+	}
 	return c
 }
 
+// 更新元素的写入版本. 版本在存储桶内单调递增, 重新插入的同一个键也会得到新的版本.
+func (c *bucket[K, V]) stamp(ele *Element[K, V]) {
+	c.writes++
+	ele.version = c.writes
+}
+
+// Unlock 释放锁, 然后投递锁内产生的异步回调通知. 投递可能等待队列有空位, 因此调用时不能持有其他存储桶的锁.
+func (c *bucket[K, V]) Unlock() {
+	var pending = c.pending
+	c.pending = nil
+	c.Mutex.Unlock()
+	c.dispatcher.dispatch(pending)
+}
+
+// 元素被删除时触发回调. 开启异步回调时复制元素并在释放锁之后投递, 否则在锁内同步调用.
+func (c *bucket[K, V]) notify(ele *Element[K, V], reason Reason) {
+	if c.dispatcher == nil {
+		ele.cb(ele, reason)
+		return
+	}
+	c.pending = append(c.pending, notification[K, V]{ele: *ele, reason: reason})
+}
+
 // Check 过期时间检查
 func (c *bucket[K, V]) Check(now int64, num int) int {
 	c.Lock()
@@ -331,26 +653,191 @@ func (c *bucket[K, V]) Check(now int64, num int) int {
 	return sum
 }
 
+// Find 沿冲突链表查找键
+func (c *bucket[K, V]) Find(hashcode uint64, key K) *Element[K, V] {
+	addr, _ := c.Map.Get(hashcode)
+	for ele := c.List.Get(addr); ele != nil; ele = c.List.Get(ele.link) {
+		if ele.Key == key {
+			return ele
+		}
+	}
+	return nil
+}
+
+// Refreshable 收集需要提前刷新的元素, 至多num个. 被收集的元素在重新加载前不会再次被收集.
+func (c *bucket[K, V]) Refreshable(now int64, num int) []refreshTask[K] {
+	c.Lock()
+	defer c.Unlock()
+
+	var tasks []refreshTask[K]
+	c.Heap.Walk(now+c.window, func(ele *Element[K, V]) bool {
+		if ele.refreshAt <= now && !ele.expired(now) {
+			ele.refreshAt = math.MaxInt64
+			tasks = append(tasks, refreshTask[K]{key: ele.Key, version: ele.version})
+		}
+		return len(tasks) < num
+	})
+	return tasks
+}
+
 func (c *bucket[K, V]) Delete(ele *Element[K, V], reason Reason) {
 	c.Heap.Delete(ele.index)
-	c.Map.Delete(ele.hashcode)
-	ele.cb(ele, reason)
+	c.unlink(ele)
+	c.Policy.Remove(ele)
+	c.stats.remove(reason)
+	c.aof.delete(ele.Key)
+	c.hub.remove(ele, reason)
+	c.cost -= ele.cost
+	if c.global != nil {
+		c.global.add(-1, -ele.cost)
+	}
+	c.notify(ele, reason)
 	c.List.Remove(ele.addr) // 必须最后删除List, 因为会清空*Element[K, V]数据
 }
 
+// SetTTL 更新过期时间, 不影响元素在淘汰策略中的位置. 软过期时间同时被重置.
+func (c *bucket[K, V]) SetTTL(ele *Element[K, V], expireAt int64) {
+	c.Heap.UpdateTTL(ele, expireAt)
+	ele.StaleAt = expireAt
+	c.updateWindow(ele)
+}
+
+// Reset 重新初始化存储桶, 返回移出的元素列表. 调用方释放锁之后通过 Drain 触发回调.
+func (c *bucket[K, V]) Reset() *deque[K, V] {
+	var list = c.List
+	c.init()
+	return list
+}
+
+// Drain 触发移出的元素的回调, 原因为reason, 已过期的元素为ReasonExpired. 调用时不能持有任何存储桶的锁.
+func (c *bucket[K, V]) Drain(list *deque[K, V], now int64, reason Reason) {
+	var total, expired uint64
+	var items []notification[K, V]
+	list.Range(func(ele *Element[K, V]) bool {
+		var r = utils.SelectValue(ele.expired(now), ReasonExpired, reason)
+		if r == ReasonExpired {
+			expired++
+		}
+		total++
+		if c.dispatcher == nil {
+			ele.cb(ele, r)
+		} else {
+			items = append(items, notification[K, V]{ele: *ele, reason: r})
+		}
+		return true
+	})
+	c.dispatcher.dispatch(items)
+
+	if c.stats != nil && total > 0 {
+		c.Lock()
+		c.stats.removals[reason] += total - expired
+		c.stats.removals[ReasonExpired] += expired
+		c.Unlock()
+	}
+}
+
 func (c *bucket[K, V]) UpdateTTL(ele *Element[K, V], expireAt int64) {
 	c.Heap.UpdateTTL(ele, expireAt)
-	c.List.MoveToBack(ele.addr)
+	ele.StaleAt = expireAt
+	c.Policy.Access(ele)
+	c.updateWindow(ele)
 }
 
-func (c *bucket[K, V]) GetElement() *Element[K, V] {
-	if c.List.Len() >= c.conf.BucketCap {
-		c.Delete(c.List.Front(), ReasonEvicted)
+// 写入开销为cost的新元素是否会超出容量
+func (c *bucket[K, V]) overflow(cost int64) bool {
+	return c.List.Len() >= c.conf.BucketCap || (c.budget > 0 && c.cost+cost > c.budget)
+}
+
+// Admit 准入检查. 开销超过存储桶上限的元素不允许写入;
+// 容量已满时, 只有新键的估计访问频率高于淘汰候选者时才允许写入.
+func (c *bucket[K, V]) Admit(hashcode uint64, cost int64) bool {
+	if c.budget > 0 && cost > c.budget {
+		return false
+	}
+	if c.global != nil && c.global.maxCost > 0 && cost > c.global.maxCost {
+		return false
+	}
+	if c.Sketch == nil || c.List.Len() == 0 || !(c.overflow(cost) || c.global.exceed(1, cost)) {
+		return true
+	}
+	var victim = c.Policy.Peek()
+	return c.Sketch.Estimate(hashcode) > c.Sketch.Estimate(victim.hashcode)
+}
+
+// GetElement 淘汰元素直到可以容纳开销为cost的新元素, 然后分配新元素
+func (c *bucket[K, V]) GetElement(cost int64) *Element[K, V] {
+	for c.List.Len() > 0 && c.overflow(cost) {
+		c.Delete(c.Policy.Victim(), ReasonEvicted)
+	}
+	for c.global.exceed(1, cost) && c.global.evict(c, nil) {
 	}
 	return c.List.PushBack()
 }
 
+// Resize 更新元素的开销, 超出开销上限时淘汰其他元素. 与写入新元素一致, 开销超过存储桶或全局上限的元素不能保留,
+// 淘汰策略选中ele本身时也会淘汰ele. ele被淘汰时返回false, 之后不能再访问ele.
+func (c *bucket[K, V]) Resize(ele *Element[K, V], cost int64) bool {
+	c.cost += cost - ele.cost
+	if c.global != nil {
+		c.global.add(0, cost-ele.cost)
+	}
+	ele.cost = cost
+	if (c.budget > 0 && cost > c.budget) || (c.global != nil && c.global.maxCost > 0 && cost > c.global.maxCost) {
+		c.Delete(ele, ReasonEvicted)
+		return false
+	}
+	for c.budget > 0 && c.cost > c.budget {
+		var victim = c.Policy.Victim()
+		c.Delete(victim, ReasonEvicted)
+		if victim == ele {
+			return false
+		}
+	}
+	for c.global.exceed(0, 0) && c.global.evict(c, ele) {
+	}
+	return true
+}
+
 func (c *bucket[K, V]) Insert(ele *Element[K, V]) {
+	c.stamp(ele)
+	if ele.StaleAt == 0 {
+		ele.StaleAt = ele.ExpireAt
+	}
 	c.Heap.Push(ele)
+	c.Policy.Insert(ele)
+	c.cost += ele.cost
+	if c.global != nil {
+		c.global.add(1, ele.cost)
+	}
+	if head, ok := c.Map.Get(ele.hashcode); ok {
+		ele.link = head
+	}
 	c.Map.Put(ele.hashcode, ele.addr)
+	c.updateWindow(ele)
+}
+
+func (c *bucket[K, V]) updateWindow(ele *Element[K, V]) {
+	if ele.refreshAt != math.MaxInt64 && ele.ExpireAt-ele.refreshAt > c.window {
+		c.window = ele.ExpireAt - ele.refreshAt
+	}
+}
+
+// 从冲突链表中移除元素
+func (c *bucket[K, V]) unlink(ele *Element[K, V]) {
+	head, _ := c.Map.Get(ele.hashcode)
+	if head == ele.addr {
+		if ele.link.IsNil() {
+			c.Map.Delete(ele.hashcode)
+		} else {
+			c.Map.Put(ele.hashcode, ele.link)
+		}
+		return
+	}
+
+	for prev := c.List.Get(head); prev != nil; prev = c.List.Get(prev.link) {
+		if prev.link == ele.addr {
+			prev.link = ele.link
+			return
+		}
+	}
 }
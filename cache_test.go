@@ -2,9 +2,11 @@ package memorycache
 
 import (
 	"math/rand"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -612,43 +614,77 @@ func TestMemoryCache_LRU(t *testing.T) {
 	}
 }
 
+func TestMemoryCache_Peek(t *testing.T) {
+	var mc = New[string, int](WithBucketNum(1), WithBucketSize(0, 2), WithStats(true), WithCachedTime(false))
+	defer mc.Stop()
+	mc.Set("a", 1, 0)
+	mc.Set("b", 2, 0)
+	mc.Set("c", 3, time.Millisecond)
+
+	v, expireAt, ok := mc.Peek("b")
+	assert.True(t, ok)
+	assert.Equal(t, v, 2)
+	assert.True(t, expireAt.IsZero())
+	_, expireAt, ok = mc.Peek("c")
+	assert.True(t, ok)
+	assert.False(t, expireAt.IsZero())
+	assert.True(t, time.Until(expireAt) <= time.Millisecond)
+	_, _, ok = mc.Peek("x")
+	assert.False(t, ok)
+	assert.Equal(t, mc.Stats().Hits+mc.Stats().Misses, uint64(0))
+
+	// 查看不会提升元素的位置, b仍然会先于c被淘汰
+	mc.Set("d", 4, 0)
+	_, _, ok = mc.Peek("b")
+	assert.False(t, ok)
+
+	time.Sleep(5 * time.Millisecond)
+	_, _, ok = mc.Peek("c")
+	assert.False(t, ok)
+}
+
+// 截断哈希值, 大量制造哈希冲突
+type collisionHasher struct{}
+
+func (c *collisionHasher) Hash(key string) uint64 {
+	return uint64(utils.Fnv32(key) & 0xFF)
+}
+
 func TestMemoryCache_Conflict(t *testing.T) {
+	var pairs = [][2]string{
+		{"O4XOUsgCQqkVCvLQ", "wYLAGPVADrDTi7VT"},
+		{"e7p5kjn8U6SDvI5B", "wbMm2kjYjwkBeqzc"},
+		{"SfZaE3dDLWcYxT6G", "x12qmBRf3TVb0oZA"},
+		{"d3n5BOTvkYif9o5T", "x4vw8ToKcrwQ8aYc"},
+		{"eR8LklziA5C9XsSl", "xXcUr3WtBNJQomaK"},
+		{"E9rj2ySsqr7DZUuU", "xkWJQCMpAWrIczTY"},
+		{"kAsRa2rcRAXvEgiB", "xtLwyg9fYRclMpsW"},
+		{"xOWb2UMFqAEML9d5", "xxVWZzckpn6LMhW7"},
+	}
+
 	t.Run("", func(t *testing.T) {
 		var mc = New[string, any]()
-		var wg = &sync.WaitGroup{}
-		wg.Add(1)
 		mc.hasher = new(utils.Fnv32Hasher)
 		mc.SetWithCallback("O4XOUsgCQqkVCvLQ", 1, time.Hour, func(element *Element[string, any], reason Reason) {
-			assert.Equal(t, element.Value, 1)
-			wg.Done()
+			t.Fail()
 		})
 		assert.False(t, mc.Set("wYLAGPVADrDTi7VT", 2, time.Hour))
 		assert.True(t, mc.Set("wYLAGPVADrDTi7VT", 2, time.Hour))
 
 		v1, ok1 := mc.Get("O4XOUsgCQqkVCvLQ")
-		assert.False(t, ok1)
-		assert.Nil(t, v1)
+		assert.True(t, ok1)
+		assert.Equal(t, v1, 1)
 
 		v2, ok2 := mc.Get("wYLAGPVADrDTi7VT")
 		assert.True(t, ok2)
 		assert.Equal(t, v2, 2)
-		assert.Equal(t, mc.Len(), 1)
-		wg.Wait()
+		assert.Equal(t, mc.Len(), 2)
 	})
 
 	t.Run("", func(t *testing.T) {
 		var mc = New[string, any]()
-		var wg = &sync.WaitGroup{}
-		wg.Add(1)
 		mc.hasher = new(utils.Fnv32Hasher)
-		mc.SetWithCallback("O4XOUsgCQqkVCvLQ", 1, time.Hour, func(element *Element[string, any], reason Reason) {
-			assert.Equal(t, element.Value, 1)
-			wg.Done()
-		})
-
-		v1, ok1 := mc.Get("O4XOUsgCQqkVCvLQ")
-		assert.True(t, ok1)
-		assert.Equal(t, v1, 1)
+		mc.Set("O4XOUsgCQqkVCvLQ", 1, time.Hour)
 
 		v2, ok2 := mc.GetOrCreate("wYLAGPVADrDTi7VT", 2, time.Hour)
 		assert.False(t, ok2)
@@ -657,12 +693,83 @@ func TestMemoryCache_Conflict(t *testing.T) {
 		v3, ok3 := mc.GetOrCreate("wYLAGPVADrDTi7VT", 3, time.Hour)
 		assert.True(t, ok3)
 		assert.Equal(t, v3, 2)
-		assert.Equal(t, mc.Len(), 1)
+		assert.Equal(t, mc.Len(), 2)
 
-		wg.Wait()
+		assert.True(t, mc.Delete("O4XOUsgCQqkVCvLQ"))
+		_, ok1 := mc.Get("O4XOUsgCQqkVCvLQ")
+		assert.False(t, ok1)
+		v4, ok4 := mc.Get("wYLAGPVADrDTi7VT")
+		assert.True(t, ok4)
+		assert.Equal(t, v4, 2)
+	})
+
+	t.Run("pairs", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(1))
+		mc.hasher = new(utils.Fnv32Hasher)
+		for i, item := range pairs {
+			assert.Equal(t, mc.hasher.Hash(item[0]), mc.hasher.Hash(item[1]))
+			mc.Set(item[0], 2*i, time.Hour)
+			mc.Set(item[1], 2*i+1, time.Hour)
+		}
+		for i, item := range pairs {
+			v0, ok0 := mc.Get(item[0])
+			v1, ok1 := mc.Get(item[1])
+			assert.True(t, ok0 && ok1)
+			assert.Equal(t, v0, 2*i)
+			assert.Equal(t, v1, 2*i+1)
+		}
+		assert.Equal(t, mc.Len(), 2*len(pairs))
+	})
+
+	t.Run("at scale", func(t *testing.T) {
+		const count = 10000
+		var mc = New[string, int](WithBucketNum(4))
+		mc.hasher = new(collisionHasher)
+		var m = make(map[string]int)
+		for i := 0; i < count; i++ {
+			var key = string(utils.AlphabetNumeric.Generate(4))
+			switch utils.AlphabetNumeric.Intn(4) {
+			case 0, 1:
+				mc.Set(key, i, time.Hour)
+				m[key] = i
+			case 2:
+				mc.Delete(key)
+				delete(m, key)
+			case 3:
+				v, ok := mc.Get(key)
+				v1, ok1 := m[key]
+				assert.Equal(t, ok1, ok)
+				assert.Equal(t, v1, v)
+			}
+		}
+		assert.Equal(t, mc.Len(), len(m))
+		for k, v := range m {
+			v1, ok := mc.Get(k)
+			assert.True(t, ok)
+			assert.Equal(t, v, v1)
+		}
+		for _, b := range mc.storage {
+			assert.True(t, isChained(b))
+		}
 	})
 }
 
+// 检查冲突链表是否覆盖了全部元素
+func isChained[K comparable, V any](b *bucket[K, V]) bool {
+	var sum = 0
+	var ok = true
+	b.Map.Iter(func(hashcode uint64, addr pointer) bool {
+		for ele := b.List.Get(addr); ele != nil; ele = b.List.Get(ele.link) {
+			if ele.hashcode != hashcode || b.Find(hashcode, ele.Key) != ele {
+				ok = false
+			}
+			sum++
+		}
+		return true
+	})
+	return ok && sum == b.List.Len()
+}
+
 func TestMemoryCache_Random(t *testing.T) {
 	t.Run("with lru", func(t *testing.T) {
 		const count = 10000
@@ -698,18 +805,232 @@ func TestMemoryCache_Random(t *testing.T) {
 		}
 
 		for _, b := range mc.storage {
-			assert.Equal(t, b.Map.Count(), b.Heap.Len())
 			assert.Equal(t, b.Heap.Len(), b.List.Len())
+			assert.True(t, isChained(b))
 			b.List.Range(func(ele *Element[string, int]) bool {
 				var v = ele
 				var v1 = b.Heap.Data[v.index]
 				assert.Equal(t, v.addr, v1)
-
-				var v2, _ = b.Map.Get(v.hashcode)
-				assert.Equal(t, v.addr, v2)
 				return true
 			})
 			assert.True(t, isSorted(b.Heap))
 		}
 	})
 }
+
+func TestMemoryCache_Cost(t *testing.T) {
+	var weigher = func(key string, value []byte) int64 { return int64(len(value)) }
+
+	t.Run("default", func(t *testing.T) {
+		var mc = New[string, int]()
+		mc.Set("a", 1, time.Hour)
+		mc.Set("b", 1, time.Hour)
+		mc.Set("b", 2, time.Hour)
+		assert.Equal(t, mc.Cost(), int64(2))
+		mc.Delete("a")
+		assert.Equal(t, mc.Cost(), int64(1))
+	})
+
+	t.Run("evict", func(t *testing.T) {
+		var mc = New[string, []byte](
+			WithBucketNum(1),
+			WithMaxCost(100),
+			WithWeigher(weigher),
+		)
+		var evicted []string
+		var cb = func(ele *Element[string, []byte], reason Reason) {
+			assert.Equal(t, reason, ReasonEvicted)
+			evicted = append(evicted, ele.Key)
+		}
+		mc.SetWithCallback("a", make([]byte, 40), time.Hour, cb)
+		mc.SetWithCallback("b", make([]byte, 40), time.Hour, cb)
+		assert.Equal(t, mc.Cost(), int64(80))
+		mc.SetWithCallback("c", make([]byte, 50), time.Hour, cb)
+		assert.Equal(t, mc.Cost(), int64(90))
+		assert.Equal(t, evicted, []string{"a"})
+		mc.SetWithCallback("d", make([]byte, 100), time.Hour, cb)
+		assert.Equal(t, mc.Cost(), int64(100))
+		assert.Equal(t, evicted, []string{"a", "b", "c"})
+		assert.ElementsMatch(t, getKeys(mc), []string{"d"})
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		var mc = New[string, []byte](
+			WithBucketNum(1),
+			WithMaxCost(100),
+			WithWeigher(weigher),
+		)
+		mc.Set("a", make([]byte, 10), time.Hour)
+		var evicted = false
+		mc.SetWithCallback("b", make([]byte, 101), time.Hour, func(ele *Element[string, []byte], reason Reason) {
+			assert.Equal(t, reason, ReasonEvicted)
+			evicted = true
+		})
+		assert.True(t, evicted)
+		assert.ElementsMatch(t, getKeys(mc), []string{"a"})
+		assert.Equal(t, mc.Cost(), int64(10))
+	})
+
+	t.Run("update", func(t *testing.T) {
+		var mc = New[string, []byte](
+			WithBucketNum(1),
+			WithMaxCost(100),
+			WithWeigher(weigher),
+		)
+		mc.Set("a", make([]byte, 30), time.Hour)
+		mc.Set("b", make([]byte, 30), time.Hour)
+		mc.Set("c", make([]byte, 30), time.Hour)
+		mc.Set("c", make([]byte, 10), time.Hour)
+		assert.Equal(t, mc.Cost(), int64(70))
+		mc.Set("b", make([]byte, 70), time.Hour)
+		assert.Equal(t, mc.Cost(), int64(80))
+		assert.ElementsMatch(t, getKeys(mc), []string{"b", "c"})
+	})
+
+	t.Run("update too large", func(t *testing.T) {
+		var mc = New[string, []byte](
+			WithBucketNum(1),
+			WithMaxCost(100),
+			WithWeigher(weigher),
+		)
+		mc.Set("a", make([]byte, 10), time.Hour)
+		mc.Set("b", make([]byte, 20), time.Hour)
+		var evicted = false
+		mc.SetWithCallback("b", make([]byte, 101), time.Hour, func(ele *Element[string, []byte], reason Reason) {
+			assert.Equal(t, reason, ReasonEvicted)
+			assert.Equal(t, len(ele.Value), 101)
+			evicted = true
+		})
+		assert.True(t, evicted)
+		assert.ElementsMatch(t, getKeys(mc), []string{"a"})
+		assert.Equal(t, mc.Cost(), int64(10))
+
+		mc.Set("c", make([]byte, 20), time.Hour)
+		_, ok := mc.Update("c", func(old []byte) []byte { return make([]byte, 101) })
+		assert.False(t, ok)
+		assert.ElementsMatch(t, getKeys(mc), []string{"a"})
+		assert.Equal(t, mc.Cost(), int64(10))
+	})
+
+	t.Run("buckets", func(t *testing.T) {
+		var mc = New[string, []byte](
+			WithBucketNum(4),
+			WithMaxCost(4000),
+			WithWeigher(weigher),
+		)
+		for i := 0; i < 1000; i++ {
+			mc.Set(string(utils.AlphabetNumeric.Generate(8)), make([]byte, utils.AlphabetNumeric.Intn(100)), time.Hour)
+		}
+		assert.LessOrEqual(t, mc.Cost(), int64(4000))
+		for _, b := range mc.storage {
+			var sum int64 = 0
+			b.List.Range(func(ele *Element[string, []byte]) bool {
+				sum += ele.cost
+				return true
+			})
+			assert.Equal(t, b.cost, sum)
+			assert.LessOrEqual(t, b.cost, int64(1000))
+		}
+	})
+}
+
+func TestMemoryCache_Clear(t *testing.T) {
+	t.Run("", func(t *testing.T) {
+		var mc = New[string, int](WithStats(true), WithCachedTime(false))
+		defer mc.Stop()
+		var reasons = map[string]Reason{}
+		var cb = func(ele *Element[string, int], reason Reason) { reasons[ele.Key] = reason }
+		mc.SetWithCallback("a", 1, time.Hour, cb)
+		mc.SetWithCallback("b", 1, time.Millisecond, cb)
+		mc.SetWithCallback("c", 1, 0, cb)
+		time.Sleep(5 * time.Millisecond)
+
+		mc.Clear()
+		assert.Equal(t, mc.Len(), 0)
+		assert.Equal(t, reasons, map[string]Reason{"a": ReasonCleared, "b": ReasonExpired, "c": ReasonCleared})
+		assert.Equal(t, mc.Stats().Evictions(ReasonCleared), uint64(2))
+		assert.Equal(t, mc.Stats().Evictions(ReasonExpired), uint64(1))
+
+		mc.Set("d", 1, time.Hour)
+		assert.Equal(t, mc.Len(), 1)
+	})
+
+	t.Run("reentrant", func(t *testing.T) {
+		// 回调在释放所有锁之后调用, 可以操作缓存
+		var mc = New[string, int](WithBucketNum(4))
+		defer mc.Stop()
+		var cb = func(ele *Element[string, int], reason Reason) {
+			mc.Set("derived:"+ele.Key, mc.Len(), 0)
+		}
+		for i := 0; i < 10; i++ {
+			mc.SetWithCallback(strconv.Itoa(i), i, 0, cb)
+		}
+		mc.Clear()
+		assert.Equal(t, mc.Len(), 10)
+		v, ok := mc.Get("derived:0")
+		assert.True(t, ok)
+		assert.Less(t, v, 10)
+	})
+}
+
+func TestMemoryCache_Close(t *testing.T) {
+	t.Run("", func(t *testing.T) {
+		var mc = New[string, int]()
+		var reasons []Reason
+		mc.SetWithCallback("a", 1, time.Hour, func(ele *Element[string, int], reason Reason) {
+			reasons = append(reasons, reason)
+		})
+		mc.Close()
+		mc.Close()
+		assert.Equal(t, reasons, []Reason{ReasonClosed})
+		assert.Equal(t, mc.Len(), 0)
+		assert.Error(t, mc.ctx.Err())
+	})
+
+	t.Run("reentrant", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(1))
+		var values []int
+		mc.SetWithCallback("a", 1, time.Hour, func(ele *Element[string, int], reason Reason) {
+			v, _ := mc.Get("b")
+			values = append(values, v)
+		})
+		mc.Set("b", 2, time.Hour)
+		mc.Close()
+		assert.Equal(t, values, []int{0})
+	})
+
+	t.Run("async", func(t *testing.T) {
+		var mc = New[string, int](WithAsyncCallback(1, OverflowBlock))
+		var count atomic.Int64
+		for i := 0; i < 100; i++ {
+			mc.SetWithCallback(strconv.Itoa(i), i, time.Hour, func(ele *Element[string, int], reason Reason) {
+				time.Sleep(time.Microsecond)
+				count.Add(1)
+			})
+		}
+		mc.Close()
+		assert.Equal(t, count.Load(), int64(100))
+	})
+
+	t.Run("aof", func(t *testing.T) {
+		var path = filepath.Join(t.TempDir(), "cache.aof")
+		var mc = New[string, int](WithAOF(path, FsyncEverySecond))
+		mc.Set("a", 1, time.Hour)
+		mc.Close()
+
+		var mc2 = New[string, int](WithAOF(path, FsyncEverySecond))
+		defer mc2.Close()
+		assert.Equal(t, mc2.Len(), 1)
+	})
+
+	t.Run("after stop", func(t *testing.T) {
+		var mc = New[string, int]()
+		var reasons []Reason
+		mc.SetWithCallback("a", 1, time.Hour, func(ele *Element[string, int], reason Reason) {
+			reasons = append(reasons, reason)
+		})
+		mc.Stop()
+		mc.Close()
+		assert.Equal(t, reasons, []Reason{ReasonClosed})
+	})
+}
@@ -0,0 +1,63 @@
+package memorycache
+
+import "sync/atomic"
+
+// 全局容量, 所有存储桶共享元素数量和开销的统计
+type capacity[K comparable, V any] struct {
+	entries, cost       atomic.Int64
+	maxEntries, maxCost int64
+	cursor              atomic.Uint64
+	storage             []*bucket[K, V]
+}
+
+func (c *capacity[K, V]) add(entries, cost int64) {
+	c.entries.Add(entries)
+	c.cost.Add(cost)
+}
+
+// 增加entries个元素和cost开销后是否超出全局容量. c为nil时总是返回false.
+func (c *capacity[K, V]) exceed(entries, cost int64) bool {
+	if c == nil {
+		return false
+	}
+	return (c.maxEntries > 0 && c.entries.Load()+entries > c.maxEntries) ||
+		(c.maxCost > 0 && c.cost.Load()+cost > c.maxCost)
+}
+
+// 淘汰一个元素, 返回是否成功.
+// 调用者已持有b的锁. 在b和两个抽样的存储桶中选择元素最多的一个淘汰, 抽样的存储桶使用TryLock, 避免死锁.
+// except 不会被淘汰.
+func (c *capacity[K, V]) evict(b *bucket[K, V], except *Element[K, V]) bool {
+	var target = b
+	var locked []*bucket[K, V]
+	for i := 0; i < 2 && len(c.storage) > 1; i++ {
+		var index = c.cursor.Add(1) & uint64(len(c.storage)-1)
+		var sample = c.storage[index]
+		if sample == b || !sample.TryLock() {
+			continue
+		}
+		locked = append(locked, sample)
+		if sample.List.Len() > target.List.Len() {
+			target = sample
+		}
+	}
+
+	var evicted = false
+	if target.List.Len() > 0 {
+		if victim := target.Policy.Peek(); victim != except {
+			target.Delete(target.Policy.Victim(), ReasonEvicted)
+			evicted = true
+		}
+	}
+
+	// 抽样的存储桶的回调通知转交给b, 在b的锁释放之后投递
+	if target != b {
+		b.pending = append(b.pending, target.pending...)
+		target.pending = nil
+	}
+
+	for _, item := range locked {
+		item.Unlock()
+	}
+	return evicted
+}
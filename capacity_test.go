@@ -0,0 +1,100 @@
+package memorycache
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lxzan/memorycache/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// 所有键都落在同一个存储桶
+type skewedHasher struct{}
+
+func (c *skewedHasher) Hash(key string) uint64 {
+	return utils.Fnv64(key) << 8
+}
+
+func TestGlobalCapacity(t *testing.T) {
+	t.Run("entries", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(16), WithGlobalCapacity(100, 0))
+		var evicted = 0
+		for i := 0; i < 1000; i++ {
+			mc.SetWithCallback(string(utils.AlphabetNumeric.Generate(16)), i, time.Hour, func(ele *Element[string, int], reason Reason) {
+				assert.Equal(t, reason, ReasonEvicted)
+				evicted++
+			})
+		}
+		assert.Equal(t, mc.Len(), 100)
+		assert.Equal(t, evicted, 900)
+		assert.Equal(t, mc.storage[0].global.entries.Load(), int64(100))
+	})
+
+	t.Run("skewed", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(16), WithBucketSize(0, 1000), WithGlobalCapacity(500, 0))
+		mc.hasher = new(skewedHasher)
+		for i := 0; i < 800; i++ {
+			mc.Set(string(utils.AlphabetNumeric.Generate(16)), i, time.Hour)
+		}
+		assert.Equal(t, mc.storage[0].List.Len(), 500)
+		assert.Equal(t, mc.Len(), 500)
+
+		// 写入其他存储桶时, 从元素最多的存储桶中淘汰
+		mc.hasher = new(utils.Fnv32Hasher)
+		for i := 0; i < 100; i++ {
+			mc.Set(string(utils.AlphabetNumeric.Generate(16)), i, time.Hour)
+		}
+		assert.Equal(t, mc.Len(), 500)
+		assert.Less(t, mc.storage[0].List.Len(), 500)
+	})
+
+	t.Run("cost", func(t *testing.T) {
+		var mc = New[string, []byte](
+			WithBucketNum(4),
+			WithGlobalCapacity(0, 1000),
+			WithWeigher(func(key string, value []byte) int64 { return int64(len(value)) }),
+		)
+		for i := 0; i < 1000; i++ {
+			mc.Set(string(utils.AlphabetNumeric.Generate(16)), make([]byte, utils.AlphabetNumeric.Intn(50)), time.Hour)
+		}
+		assert.LessOrEqual(t, mc.Cost(), int64(1000))
+		assert.Equal(t, mc.Cost(), mc.storage[0].global.cost.Load())
+
+		mc.Set("large", make([]byte, 1001), time.Hour)
+		_, ok := mc.Get("large")
+		assert.False(t, ok)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(16), WithGlobalCapacity(1000, 0))
+		var wg = &sync.WaitGroup{}
+		wg.Add(8)
+		for i := 0; i < 8; i++ {
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 10000; j++ {
+					var key = string(utils.AlphabetNumeric.Generate(4))
+					if j%4 == 0 {
+						mc.Delete(key)
+					} else {
+						mc.Set(key, j, time.Hour)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		assert.LessOrEqual(t, mc.Len(), 1000+8)
+		assert.Equal(t, int64(mc.Len()), mc.storage[0].global.entries.Load())
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(4), WithGlobalCapacity(100, 0))
+		for i := 0; i < 50; i++ {
+			mc.Set(string(utils.AlphabetNumeric.Generate(16)), i, time.Hour)
+		}
+		mc.Clear()
+		assert.Equal(t, mc.storage[0].global.entries.Load(), int64(0))
+		assert.Equal(t, mc.storage[0].global.cost.Load(), int64(0))
+	})
+}
@@ -0,0 +1,96 @@
+// memorycache-server 通过Redis协议提供缓存服务, 也可以同时提供memcached文本协议服务.
+// memorycache-server serves the cache over the Redis protocol, and optionally over the memcached text protocol.
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/lxzan/memorycache"
+	"github.com/lxzan/memorycache/memcached"
+	"github.com/lxzan/memorycache/server"
+)
+
+func main() {
+	var (
+		addr      = flag.String("addr", ":6379", "listen address")
+		mcAddr    = flag.String("memcached", "", "listen address of the memcached protocol, empty to disable")
+		bucketNum = flag.Int("bucket-num", 16, "number of buckets")
+		bucketCap = flag.Int("bucket-cap", 100000, "maximum number of entries per bucket")
+		maxMemory = flag.Int64("max-memory", 0, "maximum total size of keys and values in bytes, 0 means unlimited")
+		aof       = flag.String("aof", "", "path of the append-only log, empty to disable")
+		fsync     = flag.String("fsync", "everysec", "fsync policy of the append-only log: always, everysec or no")
+		snapshot  = flag.String("snapshot-dir", "", "directory of automatic snapshots, empty to disable")
+		interval  = flag.Duration("snapshot-interval", 5*time.Minute, "interval of automatic snapshots")
+	)
+	flag.Parse()
+
+	var options = []memorycache.Option{
+		memorycache.WithBucketNum(*bucketNum),
+		memorycache.WithBucketSize(0, *bucketCap),
+		memorycache.WithStats(true),
+	}
+	if *maxMemory > 0 {
+		options = append(options,
+			memorycache.WithGlobalCapacity(0, *maxMemory),
+			memorycache.WithWeigher(func(key string, value []byte) int64 { return int64(len(key) + len(value)) }),
+		)
+	}
+	if *aof != "" {
+		var policy = memorycache.FsyncEverySecond
+		switch *fsync {
+		case "always":
+			policy = memorycache.FsyncAlways
+		case "no":
+			policy = memorycache.FsyncNo
+		case "everysec":
+		default:
+			log.Fatalf("unknown fsync policy %q", *fsync)
+		}
+		options = append(options, memorycache.WithAOF(*aof, policy))
+	}
+	if *snapshot != "" {
+		options = append(options, memorycache.WithAutoSnapshot(*snapshot, *interval, 3))
+	}
+
+	var cache = memorycache.New[string, []byte](options...)
+	if err := cache.Err(); err != nil {
+		log.Fatalf("open persistence: %v", err)
+	}
+	// 两种协议共享同一个缓存, memcached协议的标志位和CAS唯一值不会被持久化
+	var srv = server.New(cache)
+	var mc *memcached.Server
+	if *mcAddr != "" {
+		mc = memcached.New(cache)
+		go func() {
+			log.Printf("memcached protocol listening on %s", *mcAddr)
+			if err := mc.ListenAndServe(*mcAddr); err != nil && !errors.Is(err, memcached.ErrServerClosed) {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	go func() {
+		var ch = make(chan os.Signal, 1)
+		signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+		<-ch
+		if mc != nil {
+			_ = mc.Close()
+		}
+		_ = srv.Close()
+	}()
+
+	log.Printf("memorycache-server listening on %s", *addr)
+	if err := srv.ListenAndServe(*addr); err != nil && !errors.Is(err, server.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	cache.Stop()
+	if err := cache.Err(); err != nil {
+		log.Printf("persistence error: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package memorycache
+
+import "time"
+
+// ComputeOp Compute 函数返回的操作
+// Operation returned by the Compute function
+type ComputeOp uint8
+
+const (
+	// ComputeKeep 保持不变
+	// Keep the current state
+	ComputeKeep ComputeOp = iota
+
+	// ComputeSet 写入新值和过期时间, ttl<=0表示永不过期
+	// Write the new value and expiration time, ttl<=0 means never expire
+	ComputeSet
+
+	// ComputeUpdate 写入新值并保留原有的过期时间; 键不存在时与 ComputeSet 相同
+	// Write the new value and keep the current expiration time; same as ComputeSet if the key does not exist
+	ComputeUpdate
+
+	// ComputeDelete 删除键
+	// Delete the key
+	ComputeDelete
+)
+
+// Compute 在存储桶的锁内原子地读取, 计算并写入或删除键. fn 接收当前值和键是否存在, 返回新值, 过期时间和操作.
+// 返回操作之后的值和键是否存在. fn 在锁内调用, 应当快速返回且不能操作缓存.
+// Atomically read, compute and write or delete a key under the bucket lock. fn receives the current value and whether
+// the key exists, and returns the new value, the expiration time and the operation.
+// Returns the value after the operation and whether the key exists. fn is called with the bucket lock held,
+// so it should return quickly and must not operate on the cache.
+func (c *MemoryCache[K, V]) Compute(key K, fn func(old V, exist bool) (value V, ttl time.Duration, op ComputeOp)) (actual V, exist bool) {
+	var b = c.getBucket(key)
+	b.Lock()
+	defer b.Unlock()
+
+	ele, ok := c.fetch(b, key)
+	var old V
+	if ok {
+		old = ele.Value
+	}
+
+	value, ttl, op := fn(old, ok)
+	switch {
+	case op == ComputeDelete:
+		if ok {
+			b.Delete(ele, ReasonDeleted)
+		}
+		return actual, false
+	case op == ComputeUpdate && ok:
+		b.Policy.Access(ele)
+		return value, c.replace(b, ele, value, c.weigh(key, value))
+	case op == ComputeSet || op == ComputeUpdate:
+		c.write(b, ele, ok, key, value, ttl, ttl, c.callback)
+		// 新键可能被准入策略拒绝, 更新后的值可能超出开销上限
+		return value, b.Find(b.hashcode, key) != nil
+	default:
+		return old, ok
+	}
+}
+
+// Update 原子地更新已存在的键, 保留原有的过期时间. 返回新值和键是否存在, 键不存在时不调用 fn.
+// Atomically update an existing key and keep its expiration time. Returns the new value and whether the key exists;
+// fn is not called if the key does not exist.
+func (c *MemoryCache[K, V]) Update(key K, fn func(old V) V) (value V, exist bool) {
+	return c.Compute(key, func(old V, exist bool) (V, time.Duration, ComputeOp) {
+		if !exist {
+			return old, 0, ComputeKeep
+		}
+		return fn(old), 0, ComputeUpdate
+	})
+}
+
+// CompareAndSwap 当前值等于old时替换为new, 保留原有的过期时间. 值的类型不可比较时使用 MemoryCache.CompareAndSwapFunc.
+// Swap the value for new if the current value equals old, keeping the expiration time.
+// Use MemoryCache.CompareAndSwapFunc for value types that are not comparable.
+func CompareAndSwap[K comparable, V comparable](c *MemoryCache[K, V], key K, old, new V) (swapped bool) {
+	return c.CompareAndSwapFunc(key, old, new, equal[V])
+}
+
+// CompareAndSwapFunc 与 CompareAndSwap 相同, 使用 eq 比较值, 适用于任意类型的值
+// Same as CompareAndSwap, comparing values with eq, for values of any type
+func (c *MemoryCache[K, V]) CompareAndSwapFunc(key K, old, new V, eq func(a, b V) bool) (swapped bool) {
+	c.Compute(key, func(current V, exist bool) (V, time.Duration, ComputeOp) {
+		if !exist || !eq(current, old) {
+			return current, 0, ComputeKeep
+		}
+		swapped = true
+		return new, 0, ComputeUpdate
+	})
+	return swapped
+}
+
+// CompareAndDelete 当前值等于old时删除键. 值的类型不可比较时使用 MemoryCache.CompareAndDeleteFunc.
+// Delete the key if the current value equals old. Use MemoryCache.CompareAndDeleteFunc for value types that are not comparable.
+func CompareAndDelete[K comparable, V comparable](c *MemoryCache[K, V], key K, old V) (deleted bool) {
+	return c.CompareAndDeleteFunc(key, old, equal[V])
+}
+
+// CompareAndDeleteFunc 与 CompareAndDelete 相同, 使用 eq 比较值, 适用于任意类型的值
+// Same as CompareAndDelete, comparing values with eq, for values of any type
+func (c *MemoryCache[K, V]) CompareAndDeleteFunc(key K, old V, eq func(a, b V) bool) (deleted bool) {
+	c.Compute(key, func(current V, exist bool) (V, time.Duration, ComputeOp) {
+		if !exist || !eq(current, old) {
+			return current, 0, ComputeKeep
+		}
+		deleted = true
+		return current, 0, ComputeDelete
+	})
+	return deleted
+}
+
+func equal[V comparable](a, b V) bool {
+	return a == b
+}
@@ -0,0 +1,185 @@
+package memorycache
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCache_Compute(t *testing.T) {
+	t.Run("", func(t *testing.T) {
+		var mc = New[string, int](WithCachedTime(false))
+		defer mc.Stop()
+
+		v, ok := mc.Compute("a", func(old int, exist bool) (int, time.Duration, ComputeOp) {
+			assert.False(t, exist)
+			return 1, time.Hour, ComputeSet
+		})
+		assert.True(t, ok)
+		assert.Equal(t, v, 1)
+		d := ttlOf(mc, "a")
+		assert.True(t, d > 59*time.Minute)
+
+		// 保留过期时间
+		v, ok = mc.Compute("a", func(old int, exist bool) (int, time.Duration, ComputeOp) {
+			assert.True(t, exist)
+			return old + 1, 0, ComputeUpdate
+		})
+		assert.True(t, ok)
+		assert.Equal(t, v, 2)
+		d = ttlOf(mc, "a")
+		assert.True(t, d > 59*time.Minute)
+
+		// 重新设置过期时间
+		mc.Compute("a", func(old int, exist bool) (int, time.Duration, ComputeOp) {
+			return old + 1, 0, ComputeSet
+		})
+		d = ttlOf(mc, "a")
+		assert.Equal(t, d, time.Duration(0))
+
+		v, ok = mc.Compute("a", func(old int, exist bool) (int, time.Duration, ComputeOp) {
+			return 100, 0, ComputeKeep
+		})
+		assert.True(t, ok)
+		assert.Equal(t, v, 3)
+
+		v, ok = mc.Compute("a", func(old int, exist bool) (int, time.Duration, ComputeOp) {
+			return 0, 0, ComputeDelete
+		})
+		assert.False(t, ok)
+		assert.Equal(t, mc.Len(), 0)
+
+		_, ok = mc.Compute("b", func(old int, exist bool) (int, time.Duration, ComputeOp) {
+			return 0, 0, ComputeKeep
+		})
+		assert.False(t, ok)
+		_, ok = mc.Compute("b", func(old int, exist bool) (int, time.Duration, ComputeOp) {
+			return 0, 0, ComputeDelete
+		})
+		assert.False(t, ok)
+
+		v, ok = mc.Compute("b", func(old int, exist bool) (int, time.Duration, ComputeOp) {
+			return 5, 0, ComputeUpdate
+		})
+		assert.True(t, ok)
+		assert.Equal(t, v, 5)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		var mc = New[string, int](WithCachedTime(false))
+		defer mc.Stop()
+		mc.Set("a", 1, time.Millisecond)
+		time.Sleep(5 * time.Millisecond)
+		mc.Compute("a", func(old int, exist bool) (int, time.Duration, ComputeOp) {
+			assert.False(t, exist)
+			assert.Equal(t, old, 0)
+			return 0, 0, ComputeKeep
+		})
+	})
+
+	t.Run("callback", func(t *testing.T) {
+		var reasons []Reason
+		var mc = New[string, int]()
+		defer mc.Stop()
+		mc.SetWithCallback("a", 1, 0, func(ele *Element[string, int], reason Reason) {
+			reasons = append(reasons, reason)
+		})
+		mc.Compute("a", func(old int, exist bool) (int, time.Duration, ComputeOp) {
+			return 0, 0, ComputeDelete
+		})
+		assert.Equal(t, reasons, []Reason{ReasonDeleted})
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		var mc = New[string, int]()
+		defer mc.Stop()
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					mc.Compute("n", func(old int, exist bool) (int, time.Duration, ComputeOp) {
+						return old + 1, 0, ComputeSet
+					})
+				}
+			}()
+		}
+		wg.Wait()
+		v, _ := mc.Get("n")
+		assert.Equal(t, v, 8000)
+	})
+
+	t.Run("events", func(t *testing.T) {
+		var mc = New[string, int]()
+		defer mc.Stop()
+		events, cancel := mc.Subscribe(8, nil)
+		defer cancel()
+		mc.Compute("a", func(old int, exist bool) (int, time.Duration, ComputeOp) { return 1, 0, ComputeUpdate })
+		mc.Compute("a", func(old int, exist bool) (int, time.Duration, ComputeOp) { return 2, 0, ComputeUpdate })
+		mc.Compute("a", func(old int, exist bool) (int, time.Duration, ComputeOp) { return 0, 0, ComputeDelete })
+		assert.Equal(t, (<-events).Type, EventInsert)
+		assert.Equal(t, <-events, Event[string, int]{Type: EventUpdate, Key: "a", OldValue: 1, NewValue: 2})
+		assert.Equal(t, (<-events).Type, EventDelete)
+	})
+}
+
+func TestMemoryCache_Update(t *testing.T) {
+	var mc = New[string, int](WithCachedTime(false))
+	defer mc.Stop()
+	_, ok := mc.Update("a", func(old int) int {
+		t.Fatal("unexpected call")
+		return 0
+	})
+	assert.False(t, ok)
+	assert.Equal(t, mc.Len(), 0)
+
+	mc.Set("a", 1, time.Hour)
+	v, ok := mc.Update("a", func(old int) int { return old * 10 })
+	assert.True(t, ok)
+	assert.Equal(t, v, 10)
+	d := ttlOf(mc, "a")
+	assert.True(t, d > 59*time.Minute)
+}
+
+func TestMemoryCache_CompareAndSwap(t *testing.T) {
+	t.Run("", func(t *testing.T) {
+		var mc = New[string, string](WithCachedTime(false))
+		defer mc.Stop()
+		assert.False(t, CompareAndSwap(mc, "a", "", "x"))
+		assert.Equal(t, mc.Len(), 0)
+
+		mc.Set("a", "x", time.Hour)
+		assert.False(t, CompareAndSwap(mc, "a", "y", "z"))
+		assert.True(t, CompareAndSwap(mc, "a", "x", "y"))
+		v, _ := mc.Get("a")
+		assert.Equal(t, v, "y")
+		d := ttlOf(mc, "a")
+		assert.True(t, d > 59*time.Minute)
+
+		assert.False(t, CompareAndDelete(mc, "a", "x"))
+		assert.True(t, CompareAndDelete(mc, "a", "y"))
+		assert.False(t, CompareAndDelete(mc, "a", "y"))
+		assert.Equal(t, mc.Len(), 0)
+	})
+
+	t.Run("func", func(t *testing.T) {
+		var mc = New[string, []byte]()
+		defer mc.Stop()
+		mc.Set("a", []byte("x"), 0)
+		assert.True(t, mc.CompareAndSwapFunc("a", []byte("x"), []byte("y"), bytes.Equal))
+		assert.False(t, mc.CompareAndDeleteFunc("a", []byte("x"), bytes.Equal))
+		assert.True(t, mc.CompareAndDeleteFunc("a", []byte("y"), bytes.Equal))
+	})
+}
+
+// 剩余存活时间, 永不过期时返回0
+func ttlOf[K comparable, V any](mc *MemoryCache[K, V], key K) time.Duration {
+	if _, expireAt, ok := mc.Peek(key); ok && !expireAt.IsZero() {
+		return time.Until(expireAt)
+	}
+	return 0
+}
@@ -0,0 +1,57 @@
+package memorycache
+
+import "time"
+
+// SetIfAbsent 键不存在时写入, 返回是否写入. 键存在时不修改值和过期时间. exp<=0表示永不过期.
+// 写入被准入策略或开销上限拒绝时返回false.
+// Write only if the key does not exist and return whether it was written.
+// An existing key keeps its value and expiration time. exp<=0 means never expire.
+// Returns false if the write is rejected by the admission policy or the cost limits.
+func (c *MemoryCache[K, V]) SetIfAbsent(key K, value V, exp time.Duration) (applied bool) {
+	_, stored := c.Compute(key, func(old V, exist bool) (V, time.Duration, ComputeOp) {
+		if exist {
+			return old, 0, ComputeKeep
+		}
+		applied = true
+		return value, exp, ComputeSet
+	})
+	return applied && stored
+}
+
+// SetIfPresent 键存在时写入值和过期时间, 返回是否写入. exp<=0表示永不过期.
+// 新值超出开销上限时键被淘汰, 返回false.
+// Write the value and expiration time only if the key exists and return whether it was written. exp<=0 means never expire.
+// If the new value exceeds the cost limits, the key is evicted and false is returned.
+func (c *MemoryCache[K, V]) SetIfPresent(key K, value V, exp time.Duration) (applied bool) {
+	_, stored := c.Compute(key, func(old V, exist bool) (V, time.Duration, ComputeOp) {
+		if !exist {
+			return old, 0, ComputeKeep
+		}
+		applied = true
+		return value, exp, ComputeSet
+	})
+	return applied && stored
+}
+
+// Swap 写入值和过期时间, 返回之前的值, 键是否存在和新值是否写入. exp<=0表示永不过期.
+// 新值被准入策略或开销上限拒绝时stored为false, 此时键已经不存在.
+// Write the value and expiration time, and return the previous value, whether the key existed and whether the new value
+// was stored. exp<=0 means never expire. stored is false if the new value is rejected by the admission policy or the cost
+// limits, in which case the key no longer exists.
+func (c *MemoryCache[K, V]) Swap(key K, value V, exp time.Duration) (previous V, loaded, stored bool) {
+	_, stored = c.Compute(key, func(old V, exist bool) (V, time.Duration, ComputeOp) {
+		previous, loaded = old, exist
+		return value, exp, ComputeSet
+	})
+	return previous, loaded, stored
+}
+
+// GetAndDelete 删除键, 返回删除前的值和键是否存在
+// Delete the key and return the value before deletion and whether the key existed
+func (c *MemoryCache[K, V]) GetAndDelete(key K) (v V, exist bool) {
+	c.Compute(key, func(old V, ok bool) (V, time.Duration, ComputeOp) {
+		v, exist = old, ok
+		return old, 0, ComputeDelete
+	})
+	return v, exist
+}
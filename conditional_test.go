@@ -0,0 +1,140 @@
+package memorycache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCache_SetIfAbsent(t *testing.T) {
+	t.Run("", func(t *testing.T) {
+		var mc = New[string, int](WithCachedTime(false))
+		defer mc.Stop()
+		assert.True(t, mc.SetIfAbsent("a", 1, time.Hour))
+		assert.False(t, mc.SetIfAbsent("a", 2, time.Minute))
+		v, _ := mc.Get("a")
+		assert.Equal(t, v, 1)
+
+		// 不刷新过期时间
+		d := ttlOf(mc, "a")
+		assert.True(t, d > 59*time.Minute)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		var mc = New[string, int](WithCachedTime(false))
+		defer mc.Stop()
+		mc.Set("a", 1, time.Millisecond)
+		time.Sleep(5 * time.Millisecond)
+		assert.True(t, mc.SetIfAbsent("a", 2, 0))
+		v, _ := mc.Get("a")
+		assert.Equal(t, v, 2)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		var mc = New[string, int]()
+		defer mc.Stop()
+		var wg sync.WaitGroup
+		var applied atomic.Int64
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if mc.SetIfAbsent("lock", i, time.Second) {
+					applied.Add(1)
+				}
+			}(i)
+		}
+		wg.Wait()
+		assert.Equal(t, applied.Load(), int64(1))
+	})
+}
+
+func TestMemoryCache_SetIfPresent(t *testing.T) {
+	var mc = New[string, int](WithCachedTime(false))
+	defer mc.Stop()
+	assert.False(t, mc.SetIfPresent("a", 1, 0))
+	assert.Equal(t, mc.Len(), 0)
+
+	mc.Set("a", 1, 0)
+	assert.True(t, mc.SetIfPresent("a", 2, time.Hour))
+	v, _ := mc.Get("a")
+	assert.Equal(t, v, 2)
+	d := ttlOf(mc, "a")
+	assert.True(t, d > 59*time.Minute)
+}
+
+func TestMemoryCache_Swap(t *testing.T) {
+	var mc = New[string, int](WithCachedTime(false))
+	defer mc.Stop()
+	v, loaded, stored := mc.Swap("a", 1, 0)
+	assert.False(t, loaded)
+	assert.True(t, stored)
+	assert.Equal(t, v, 0)
+
+	v, loaded, stored = mc.Swap("a", 2, time.Hour)
+	assert.True(t, loaded)
+	assert.True(t, stored)
+	assert.Equal(t, v, 1)
+	v, _ = mc.Get("a")
+	assert.Equal(t, v, 2)
+	d := ttlOf(mc, "a")
+	assert.True(t, d > 59*time.Minute)
+}
+
+func TestMemoryCache_Rejected(t *testing.T) {
+	// 开销超过上限的写入被拒绝
+	var newCache = func() *MemoryCache[string, int] {
+		return New[string, int](WithBucketNum(1), WithMaxCost(10), WithWeigher(func(key string, value int) int64 {
+			return int64(value)
+		}))
+	}
+
+	t.Run("set if absent", func(t *testing.T) {
+		var mc = newCache()
+		defer mc.Stop()
+		assert.False(t, mc.SetIfAbsent("a", 20, 0))
+		assert.Equal(t, mc.Len(), 0)
+		assert.True(t, mc.SetIfAbsent("a", 5, 0))
+	})
+
+	t.Run("set if present", func(t *testing.T) {
+		var mc = newCache()
+		defer mc.Stop()
+		mc.Set("a", 1, 0)
+		assert.False(t, mc.SetIfPresent("a", 20, 0))
+		assert.Equal(t, mc.Len(), 0)
+	})
+
+	t.Run("swap", func(t *testing.T) {
+		var mc = newCache()
+		defer mc.Stop()
+		mc.Set("a", 1, 0)
+		v, loaded, stored := mc.Swap("a", 20, 0)
+		assert.Equal(t, v, 1)
+		assert.True(t, loaded)
+		assert.False(t, stored)
+		assert.Equal(t, mc.Len(), 0)
+	})
+}
+
+func TestMemoryCache_GetAndDelete(t *testing.T) {
+	var reasons []Reason
+	var mc = New[string, int]()
+	defer mc.Stop()
+	_, ok := mc.GetAndDelete("a")
+	assert.False(t, ok)
+
+	mc.SetWithCallback("a", 1, 0, func(ele *Element[string, int], reason Reason) {
+		reasons = append(reasons, reason)
+	})
+	v, ok := mc.GetAndDelete("a")
+	assert.True(t, ok)
+	assert.Equal(t, v, 1)
+	assert.Equal(t, mc.Len(), 0)
+	assert.Equal(t, reasons, []Reason{ReasonDeleted})
+	_, ok = mc.GetAndDelete("a")
+	assert.False(t, ok)
+}
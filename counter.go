@@ -0,0 +1,51 @@
+package memorycache
+
+import "time"
+
+// Counter 整数计数器, 可用于限流和配额. 内嵌 MemoryCache, 其他方法与缓存相同.
+// Integer counter for rate limiting and quotas. It embeds MemoryCache, so the other methods are the same as the cache.
+type Counter[K comparable] struct {
+	*MemoryCache[K, int64]
+}
+
+// NewCounter 创建计数器
+// Create a counter
+func NewCounter[K comparable](options ...Option) *Counter[K] {
+	return &Counter[K]{MemoryCache: New[K, int64](options...)}
+}
+
+// Incr 加1, 见 IncrBy
+// Add 1, see IncrBy
+func (c *Counter[K]) Incr(key K, ttl time.Duration) (int64, bool) {
+	return c.IncrBy(key, 1, ttl)
+}
+
+// Decr 减1, 见 IncrBy
+// Subtract 1, see IncrBy
+func (c *Counter[K]) Decr(key K, ttl time.Duration) (int64, bool) {
+	return c.IncrBy(key, -1, ttl)
+}
+
+// IncrBy 原子地加上delta, 返回新值和计数器是否存在. 键不存在时初始化为delta, 过期时间为ttl, ttl<=0表示永不过期;
+// 键存在时保留原有的过期时间, 适用于固定窗口的计数. 溢出时按int64回绕.
+// 新的计数器可能被准入策略或开销上限拒绝, 此时返回false, 调用方不应当依赖返回的新值.
+// Atomically add delta and return the new value and whether the counter exists. If the key does not exist,
+// it is initialized to delta with the expiration time ttl, where ttl<=0 means never expire; if it exists,
+// the expiration time is kept, which suits fixed-window counting. Overflow wraps around as int64.
+// A new counter may be rejected by the admission policy or the cost limit, in which case false is returned
+// and the caller should not rely on the new value.
+func (c *Counter[K]) IncrBy(key K, delta int64, ttl time.Duration) (int64, bool) {
+	return c.Compute(key, func(old int64, exist bool) (int64, time.Duration, ComputeOp) {
+		return old + delta, ttl, ComputeUpdate
+	})
+}
+
+// IncrByAndExpire 原子地加上delta, 同时将过期时间重置为ttl, ttl<=0表示永不过期. 适用于滑动过期的计数.
+// 返回值与 IncrBy 相同.
+// Atomically add delta, resetting the expiration time to ttl, where ttl<=0 means never expire.
+// Suits counting with sliding expiration. The return values are the same as IncrBy.
+func (c *Counter[K]) IncrByAndExpire(key K, delta int64, ttl time.Duration) (int64, bool) {
+	return c.Compute(key, func(old int64, exist bool) (int64, time.Duration, ComputeOp) {
+		return old + delta, ttl, ComputeSet
+	})
+}
@@ -0,0 +1,98 @@
+package memorycache
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 检查计数器已写入并返回新值
+func must(t *testing.T) func(v int64, ok bool) int64 {
+	return func(v int64, ok bool) int64 {
+		assert.True(t, ok)
+		return v
+	}
+}
+
+func TestCounter_IncrBy(t *testing.T) {
+	t.Run("", func(t *testing.T) {
+		var c = NewCounter[string](WithCachedTime(false))
+		defer c.Stop()
+		assert.Equal(t, must(t)(c.Incr("a", time.Hour)), int64(1))
+		assert.Equal(t, must(t)(c.IncrBy("a", 10, time.Minute)), int64(11))
+		assert.Equal(t, must(t)(c.Decr("a", 0)), int64(10))
+		assert.Equal(t, must(t)(c.IncrBy("b", -5, 0)), int64(-5))
+
+		// 保留初始化时的过期时间
+		d := ttlOf(c.MemoryCache, "a")
+		assert.True(t, d > 59*time.Minute)
+		d = ttlOf(c.MemoryCache, "b")
+		assert.Equal(t, d, time.Duration(0))
+
+		v, ok := c.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, v, int64(10))
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		var c = NewCounter[string](WithCachedTime(false))
+		defer c.Stop()
+		assert.Equal(t, must(t)(c.IncrBy("a", 5, 10*time.Millisecond)), int64(5))
+		assert.Equal(t, must(t)(c.IncrBy("a", 5, time.Hour)), int64(10))
+		time.Sleep(20 * time.Millisecond)
+		assert.Equal(t, must(t)(c.IncrBy("a", 5, time.Hour)), int64(5))
+	})
+
+	t.Run("overflow", func(t *testing.T) {
+		var c = NewCounter[string]()
+		defer c.Stop()
+		c.Set("a", math.MaxInt64, 0)
+		assert.Equal(t, must(t)(c.Incr("a", 0)), int64(math.MinInt64))
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		var c = NewCounter[string](WithBucketNum(1), WithMaxCost(1), WithWeigher(func(key string, value int64) int64 { return 2 }))
+		defer c.Stop()
+		v, ok := c.IncrBy("a", 5, 0)
+		assert.False(t, ok)
+		assert.Equal(t, v, int64(5))
+		_, ok = c.IncrByAndExpire("a", 5, time.Hour)
+		assert.False(t, ok)
+		assert.Equal(t, c.Len(), 0)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		var c = NewCounter[int](WithBucketNum(1))
+		defer c.Stop()
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					c.Incr(j%10, time.Hour)
+				}
+			}()
+		}
+		wg.Wait()
+		for i := 0; i < 10; i++ {
+			v, _ := c.Get(i)
+			assert.Equal(t, v, int64(800))
+		}
+	})
+}
+
+func TestCounter_IncrByAndExpire(t *testing.T) {
+	var c = NewCounter[string](WithCachedTime(false))
+	defer c.Stop()
+	assert.Equal(t, must(t)(c.IncrByAndExpire("a", 2, time.Hour)), int64(2))
+	assert.Equal(t, must(t)(c.IncrByAndExpire("a", 3, time.Minute)), int64(5))
+	d := ttlOf(c.MemoryCache, "a")
+	assert.True(t, d > 59*time.Second && d <= time.Minute)
+	assert.Equal(t, must(t)(c.IncrByAndExpire("a", 1, 0)), int64(6))
+	d = ttlOf(c.MemoryCache, "a")
+	assert.Equal(t, d, time.Duration(0))
+}
@@ -93,6 +93,20 @@ func (c *deque[K, V]) doPushBack(ele *Element[K, V]) {
 	c.tail = ele.addr
 }
 
+func (c *deque[K, V]) doPushFront(ele *Element[K, V]) {
+	c.length++
+
+	if c.head.IsNil() {
+		c.head, c.tail = ele.addr, ele.addr
+		return
+	}
+
+	head := c.Get(c.head)
+	head.prev = ele.addr
+	ele.next = head.addr
+	c.head = ele.addr
+}
+
 func (c *deque[K, V]) PopFront() (value Element[K, V]) {
 	if ele := c.Front(); ele != nil {
 		value = *ele
@@ -113,6 +127,34 @@ func (c *deque[K, V]) MoveToBack(addr pointer) {
 	}
 }
 
+func (c *deque[K, V]) MoveToFront(addr pointer) {
+	if ele := c.Get(addr); ele != nil {
+		c.doRemove(ele)
+		ele.prev, ele.next = null, null
+		c.doPushFront(ele)
+	}
+}
+
+// MoveAfter 将元素移动到mark之后
+func (c *deque[K, V]) MoveAfter(addr, mark pointer) {
+	ele, at := c.Get(addr), c.Get(mark)
+	if ele == nil || at == nil || addr == mark {
+		return
+	}
+
+	c.doRemove(ele)
+	if at.next.IsNil() {
+		ele.prev, ele.next = null, null
+		c.doPushBack(ele)
+		return
+	}
+
+	c.length++
+	next := c.Get(at.next)
+	ele.prev, ele.next = at.addr, next.addr
+	at.next, next.prev = ele.addr, ele.addr
+}
+
 func (c *deque[K, V]) Remove(addr pointer) {
 	if ele := c.Get(addr); ele != nil {
 		c.doRemove(ele)
@@ -54,7 +54,7 @@ func TestQueue_Random(t *testing.T) {
 	var q = newDeque[int, int](0)
 	var linkedlist = list.New()
 	for i := 0; i < count; i++ {
-		var flag = rand.Intn(7)
+		var flag = rand.Intn(9)
 		var val = rand.Int()
 		switch flag {
 		case 0, 1, 2, 3:
@@ -105,6 +105,54 @@ func TestQueue_Random(t *testing.T) {
 					break
 				}
 			}
+		case 7:
+			var n = rand.Intn(10)
+			var index = 0
+			for iter := q.Front(); iter != nil; iter = q.Get(iter.next) {
+				index++
+				if index >= n {
+					q.MoveToFront(iter.addr)
+					break
+				}
+			}
+
+			index = 0
+			for iter := linkedlist.Front(); iter != nil; iter = iter.Next() {
+				index++
+				if index >= n {
+					linkedlist.MoveToFront(iter)
+					break
+				}
+			}
+		case 8:
+			var n, m = rand.Intn(10), rand.Intn(10)
+			var p0, p1 pointer
+			var index = 0
+			for iter := q.Front(); iter != nil; iter = q.Get(iter.next) {
+				if index == n {
+					p0 = iter.addr
+				}
+				if index == m {
+					p1 = iter.addr
+				}
+				index++
+			}
+			q.MoveAfter(p0, p1)
+
+			var e0, e1 *list.Element
+			index = 0
+			for iter := linkedlist.Front(); iter != nil; iter = iter.Next() {
+				if index == n {
+					e0 = iter
+				}
+				if index == m {
+					e1 = iter
+				}
+				index++
+			}
+			if e0 != nil && e1 != nil {
+				linkedlist.MoveAfter(e0, e1)
+			}
 		default:
 
 		}
@@ -0,0 +1,167 @@
+package memorycache
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy 异步回调队列已满时的处理策略
+// Policy applied when the asynchronous callback queue is full
+type OverflowPolicy uint8
+
+const (
+	OverflowDiscard       OverflowPolicy = iota // 丢弃新的通知, 默认策略
+	OverflowDiscardOldest                       // 丢弃最早的通知
+	OverflowBlock                               // 等待队列有空位, 等待期间不持有存储桶的锁. 回调中写入缓存产生的通知不等待, 在当前回调返回后投递
+)
+
+const defaultCallbackQueueSize = 1024
+
+// 回调通知, 元素是删除前的副本
+type notification[K comparable, V any] struct {
+	ele    Element[K, V]
+	reason Reason
+}
+
+// 异步回调分发器. 通知由一个协程按顺序投递, 回调在任何存储桶的锁之外执行.
+type dispatcher[K comparable, V any] struct {
+	mu       sync.RWMutex
+	closed   bool
+	quit     chan struct{}
+	once     sync.Once
+	overflow OverflowPolicy
+	queue    chan notification[K, V]
+	done     chan struct{}
+	drops    atomic.Uint64
+
+	// 投递协程的ID, 以及回调中写入缓存时暂存的通知. backlog只被投递协程访问.
+	gid     atomic.Uint64
+	backlog []notification[K, V]
+}
+
+func newDispatcher[K comparable, V any](size int, overflow OverflowPolicy) *dispatcher[K, V] {
+	var c = &dispatcher[K, V]{
+		overflow: overflow,
+		queue:    make(chan notification[K, V], size),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go func() {
+		defer close(c.done)
+		c.gid.Store(goid())
+		for item := range c.queue {
+			item.ele.cb(&item.ele, item.reason)
+			c.flush()
+		}
+	}()
+	return c
+}
+
+// 投递回调执行期间暂存的通知, 这些通知的回调产生的新通知也会追加到backlog
+func (c *dispatcher[K, V]) flush() {
+	for i := 0; i < len(c.backlog); i++ {
+		var item = c.backlog[i]
+		item.ele.cb(&item.ele, item.reason)
+	}
+	for i := range c.backlog {
+		c.backlog[i] = notification[K, V]{}
+	}
+	c.backlog = c.backlog[:0]
+}
+
+// 投递存储桶在锁内收集的通知, 调用方不能持有任何存储桶的锁. 分发器已关闭时同步调用回调.
+func (c *dispatcher[K, V]) dispatch(items []notification[K, V]) {
+	if c == nil || len(items) == 0 {
+		return
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for i := range items {
+		if c.closed {
+			items[i].ele.cb(&items[i].ele, items[i].reason)
+			continue
+		}
+		c.enqueue(items[i])
+	}
+}
+
+func (c *dispatcher[K, V]) enqueue(item notification[K, V]) {
+	switch c.overflow {
+	case OverflowBlock:
+		select {
+		case c.queue <- item:
+			return
+		default:
+		}
+
+		// 回调中写入缓存时调用方就是投递协程, 等待队列有空位会阻塞自身, 改为暂存
+		if goid() == c.gid.Load() {
+			c.backlog = append(c.backlog, item)
+			return
+		}
+
+		// 关闭时不再等待, 改为同步调用
+		select {
+		case c.queue <- item:
+		case <-c.quit:
+			item.ele.cb(&item.ele, item.reason)
+		}
+	case OverflowDiscardOldest:
+		for {
+			select {
+			case c.queue <- item:
+				return
+			default:
+			}
+			select {
+			case <-c.queue:
+				c.drops.Add(1)
+			default:
+			}
+		}
+	default:
+		select {
+		case c.queue <- item:
+		default:
+			c.drops.Add(1)
+		}
+	}
+}
+
+// 关闭分发器, 等待队列中的通知投递完成
+func (c *dispatcher[K, V]) close() {
+	if c == nil {
+		return
+	}
+	// 先唤醒等待入队的调用方, 否则写锁会一直等待它们释放读锁
+	c.once.Do(func() { close(c.quit) })
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.queue)
+	}
+	c.mu.Unlock()
+	<-c.done
+}
+
+func (c *dispatcher[K, V]) dropped() uint64 {
+	if c == nil {
+		return 0
+	}
+	return c.drops.Load()
+}
+
+// 当前协程的ID, 从调用栈的第一行 "goroutine N [...]" 中解析. 只在队列已满时调用.
+func goid() uint64 {
+	var buf [64]byte
+	var s = bytes.TrimPrefix(buf[:runtime.Stack(buf[:], false)], []byte("goroutine "))
+	if i := bytes.IndexByte(s, ' '); i >= 0 {
+		s = s[:i]
+	}
+	id, _ := strconv.ParseUint(string(s), 10, 64)
+	return id
+}
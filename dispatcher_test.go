@@ -0,0 +1,163 @@
+package memorycache
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCache_AsyncCallback(t *testing.T) {
+	t.Run("reentrant", func(t *testing.T) {
+		var mc = New[string, int](WithAsyncCallback(16, OverflowBlock), WithBucketNum(1))
+		defer mc.Stop()
+		var done = make(chan struct{})
+		mc.SetWithCallback("a", 1, time.Hour, func(ele *Element[string, int], reason Reason) {
+			mc.Set("derived:"+ele.Key, ele.Value+1, time.Hour)
+			close(done)
+		})
+		mc.Delete("a")
+		<-done
+		v, ok := mc.Get("derived:a")
+		assert.True(t, ok)
+		assert.Equal(t, v, 2)
+	})
+
+	t.Run("reentrant overflow", func(t *testing.T) {
+		// 回调中的写入淘汰了其他元素, 队列已满时投递协程不能等待自身
+		var mc = New[int, int](WithAsyncCallback(1, OverflowBlock), WithBucketNum(1), WithBucketSize(0, 4))
+		var count atomic.Int64
+		var cb CallbackFunc[*Element[int, int]]
+		cb = func(ele *Element[int, int], reason Reason) {
+			if ele.Key < 1000 {
+				mc.SetWithCallback(ele.Key+1000, ele.Value, time.Hour, cb)
+			}
+			count.Add(1)
+		}
+		var done = make(chan struct{})
+		go func() {
+			for i := 0; i < 100; i++ {
+				mc.SetWithCallback(i, i, time.Hour, cb)
+			}
+			mc.Stop()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("deadlock")
+		}
+		assert.GreaterOrEqual(t, count.Load(), int64(96))
+	})
+
+	t.Run("order", func(t *testing.T) {
+		var mc = New[string, int](WithAsyncCallback(16, OverflowBlock), WithBucketNum(1), WithBucketSize(0, 2))
+		var mu sync.Mutex
+		var keys []string
+		var reasons []Reason
+		var cb = func(ele *Element[string, int], reason Reason) {
+			mu.Lock()
+			keys = append(keys, ele.Key)
+			reasons = append(reasons, reason)
+			mu.Unlock()
+		}
+		mc.SetWithCallback("a", 1, time.Hour, cb)
+		mc.SetWithCallback("b", 1, time.Hour, cb)
+		mc.SetWithCallback("c", 1, time.Hour, cb)
+		mc.Delete("b")
+		mc.Stop()
+		assert.Equal(t, keys, []string{"a", "b"})
+		assert.Equal(t, reasons, []Reason{ReasonEvicted, ReasonDeleted})
+
+		// 停止后同步调用
+		mc.Delete("c")
+		assert.Equal(t, keys, []string{"a", "b", "c"})
+	})
+
+	t.Run("block", func(t *testing.T) {
+		// 队列已满时不能持有存储桶的锁等待, 否则回调中的写入会死锁
+		var mc = New[string, int](WithAsyncCallback(2, OverflowBlock), WithBucketNum(1))
+		var count atomic.Int64
+		var cb = func(ele *Element[string, int], reason Reason) {
+			mc.Set("derived:"+ele.Key, ele.Value, time.Hour)
+			count.Add(1)
+		}
+		for i := 0; i < 100; i++ {
+			mc.SetWithCallback(strconv.Itoa(i), i, time.Hour, cb)
+		}
+		var done = make(chan struct{})
+		go func() {
+			for i := 0; i < 100; i++ {
+				mc.Delete(strconv.Itoa(i))
+			}
+			mc.Stop()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("deadlock")
+		}
+		assert.Equal(t, count.Load(), int64(100))
+		assert.Equal(t, mc.Len(), 100)
+	})
+
+	var blocking = func(overflow OverflowPolicy) (mc *MemoryCache[string, int], keys *[]string, release func()) {
+		mc = New[string, int](WithAsyncCallback(1, overflow))
+		var entered = make(chan struct{}, 1)
+		var gate = make(chan struct{})
+		keys = new([]string)
+		var cb = func(ele *Element[string, int], reason Reason) {
+			select {
+			case entered <- struct{}{}:
+			default:
+			}
+			<-gate
+			*keys = append(*keys, ele.Key)
+		}
+		for _, key := range []string{"a", "b", "c"} {
+			mc.SetWithCallback(key, 1, time.Hour, cb)
+		}
+		mc.Delete("a")
+		<-entered
+		mc.Delete("b")
+		mc.Delete("c")
+		return mc, keys, func() {
+			close(gate)
+			mc.Stop()
+		}
+	}
+
+	t.Run("discard", func(t *testing.T) {
+		mc, keys, release := blocking(OverflowDiscard)
+		assert.Equal(t, mc.Stats().DroppedCallbacks, uint64(1))
+		release()
+		assert.Equal(t, *keys, []string{"a", "b"})
+		mc.ResetStats()
+		assert.Equal(t, mc.Stats().DroppedCallbacks, uint64(0))
+	})
+
+	t.Run("discard oldest", func(t *testing.T) {
+		mc, keys, release := blocking(OverflowDiscardOldest)
+		assert.Equal(t, mc.Stats().DroppedCallbacks, uint64(1))
+		release()
+		assert.Equal(t, *keys, []string{"a", "c"})
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		var mc = New[string, int](WithAsyncCallback(0, OverflowBlock), WithMaxCost(1), WithBucketNum(1), WithWeigher(func(key string, value int) int64 {
+			return int64(value)
+		}))
+		assert.Equal(t, mc.conf.CallbackQueueSize, defaultCallbackQueueSize)
+		var done = make(chan Reason, 1)
+		mc.SetWithCallback("a", 2, time.Hour, func(ele *Element[string, int], reason Reason) {
+			assert.Equal(t, ele.Key, "a")
+			done <- reason
+		})
+		assert.Equal(t, <-done, ReasonEvicted)
+		mc.Stop()
+	})
+}
@@ -0,0 +1,150 @@
+package memorycache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// EventType 缓存事件类型
+// Type of cache event
+type EventType uint8
+
+const (
+	EventInsert EventType = iota // 新增
+	EventUpdate                  // 更新
+	EventDelete                  // 被删除
+	EventExpire                  // 过期
+	EventEvict                   // 被驱逐
+	EventClear                   // 清空缓存
+)
+
+func (c EventType) String() string {
+	switch c {
+	case EventInsert:
+		return "insert"
+	case EventUpdate:
+		return "update"
+	case EventDelete:
+		return "delete"
+	case EventExpire:
+		return "expire"
+	case EventEvict:
+		return "evict"
+	case EventClear:
+		return "clear"
+	default:
+		return "unknown"
+	}
+}
+
+// Event 缓存事件. OldValue 仅用于更新和删除类事件, NewValue 仅用于新增和更新事件,
+// Reason 仅用于删除类事件(被删除, 过期, 被驱逐). 清空事件不携带键值.
+// Cache event. OldValue is only set for update and removal events, NewValue only for insert and update events,
+// and Reason only for removal events (delete, expire, evict). Clear events carry no key or value.
+type Event[K comparable, V any] struct {
+	Type     EventType
+	Key      K
+	OldValue V
+	NewValue V
+	Reason   Reason
+}
+
+type subscriber[K comparable, V any] struct {
+	ch     chan Event[K, V]
+	filter func(Event[K, V]) bool
+}
+
+// 事件订阅中心. 事件在存储桶的锁内发布, 因此同一个键的事件是有序的.
+type hub[K comparable, V any] struct {
+	mu          sync.RWMutex
+	closed      bool
+	seq         uint64
+	subscribers map[uint64]*subscriber[K, V]
+	active      atomic.Int32 // 订阅者数量, 没有订阅者时跳过事件的构造
+}
+
+func newHub[K comparable, V any]() *hub[K, V] {
+	return &hub[K, V]{subscribers: make(map[uint64]*subscriber[K, V])}
+}
+
+// 是否有订阅者
+func (c *hub[K, V]) enabled() bool {
+	return c.active.Load() > 0
+}
+
+// 发布事件. 订阅者的缓冲区已满时丢弃该事件, 不会阻塞写入.
+func (c *hub[K, V]) publish(event Event[K, V]) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, item := range c.subscribers {
+		if item.filter != nil && !item.filter(event) {
+			continue
+		}
+		select {
+		case item.ch <- event:
+		default:
+		}
+	}
+}
+
+// 发布删除类事件
+func (c *hub[K, V]) remove(ele *Element[K, V], reason Reason) {
+	if !c.enabled() {
+		return
+	}
+	var event = Event[K, V]{Type: EventDelete, Key: ele.Key, OldValue: ele.Value, Reason: reason}
+	switch reason {
+	case ReasonExpired:
+		event.Type = EventExpire
+	case ReasonEvicted:
+		event.Type = EventEvict
+	}
+	c.publish(event)
+}
+
+func (c *hub[K, V]) subscribe(buffer int, filter func(Event[K, V]) bool) (<-chan Event[K, V], func()) {
+	var item = &subscriber[K, V]{ch: make(chan Event[K, V], buffer), filter: filter}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		close(item.ch)
+		return item.ch, func() {}
+	}
+
+	c.seq++
+	var id = c.seq
+	c.subscribers[id] = item
+	c.active.Add(1)
+	return item.ch, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if _, ok := c.subscribers[id]; ok {
+			delete(c.subscribers, id)
+			c.active.Add(-1)
+			close(item.ch)
+		}
+	}
+}
+
+// 关闭所有订阅
+func (c *hub[K, V]) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for id, item := range c.subscribers {
+		delete(c.subscribers, id)
+		close(item.ch)
+	}
+	c.active.Store(0)
+}
+
+// Subscribe 订阅缓存事件. buffer为通道的缓冲区大小, 缓冲区已满时新事件被丢弃, 不会阻塞缓存的写入.
+// filter为nil时接收所有事件; filter在存储桶的锁内调用, 应当快速返回且不能操作缓存.
+// cancel 取消订阅并关闭通道; Stop 会关闭所有订阅.
+// Subscribe to cache events. buffer is the buffer size of the channel; when it is full, new events are dropped
+// instead of blocking writes to the cache. A nil filter receives all events; filter is called with the bucket lock held,
+// so it should return quickly and must not operate on the cache.
+// cancel unsubscribes and closes the channel; Stop closes all subscriptions.
+func (c *MemoryCache[K, V]) Subscribe(buffer int, filter func(Event[K, V]) bool) (events <-chan Event[K, V], cancel func()) {
+	return c.hub.subscribe(buffer, filter)
+}
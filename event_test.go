@@ -0,0 +1,112 @@
+package memorycache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func drainEvents[K comparable, V any](ch <-chan Event[K, V]) []Event[K, V] {
+	var list []Event[K, V]
+	for {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				return list
+			}
+			list = append(list, event)
+		default:
+			return list
+		}
+	}
+}
+
+func TestMemoryCache_Subscribe(t *testing.T) {
+	t.Run("events", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(1), WithBucketSize(0, 2), WithCachedTime(false))
+		defer mc.Stop()
+		ch, cancel := mc.Subscribe(16, nil)
+		defer cancel()
+
+		mc.Set("a", 1, time.Hour)
+		mc.Set("a", 2, time.Hour)
+		mc.Set("b", 1, time.Millisecond)
+		time.Sleep(5 * time.Millisecond)
+		mc.Get("b")
+		mc.GetOrCreate("c", 3, time.Hour)
+		mc.Set("d", 4, time.Hour)
+		mc.Delete("d")
+		mc.Clear()
+
+		assert.Equal(t, drainEvents(ch), []Event[string, int]{
+			{Type: EventInsert, Key: "a", NewValue: 1},
+			{Type: EventUpdate, Key: "a", OldValue: 1, NewValue: 2},
+			{Type: EventInsert, Key: "b", NewValue: 1},
+			{Type: EventExpire, Key: "b", OldValue: 1, Reason: ReasonExpired},
+			{Type: EventInsert, Key: "c", NewValue: 3},
+			{Type: EventEvict, Key: "a", OldValue: 2, Reason: ReasonEvicted},
+			{Type: EventInsert, Key: "d", NewValue: 4},
+			{Type: EventDelete, Key: "d", OldValue: 4, Reason: ReasonDeleted},
+			{Type: EventClear},
+		})
+	})
+
+	t.Run("filter and fan-out", func(t *testing.T) {
+		var mc = New[string, int]()
+		defer mc.Stop()
+		ch1, cancel1 := mc.Subscribe(16, func(event Event[string, int]) bool {
+			return event.Type == EventDelete
+		})
+		ch2, cancel2 := mc.Subscribe(16, nil)
+		defer cancel2()
+
+		mc.Set("a", 1, time.Hour)
+		mc.Delete("a")
+		assert.Equal(t, len(drainEvents(ch1)), 1)
+		assert.Equal(t, len(drainEvents(ch2)), 2)
+
+		cancel1()
+		cancel1()
+		_, ok := <-ch1
+		assert.False(t, ok)
+		mc.Set("b", 1, time.Hour)
+		assert.Equal(t, len(drainEvents(ch2)), 1)
+	})
+
+	t.Run("full buffer", func(t *testing.T) {
+		var mc = New[string, int]()
+		defer mc.Stop()
+		ch, cancel := mc.Subscribe(1, nil)
+		defer cancel()
+		mc.Set("a", 1, time.Hour)
+		mc.Set("b", 1, time.Hour)
+		var list = drainEvents(ch)
+		assert.Equal(t, len(list), 1)
+		assert.Equal(t, list[0].Key, "a")
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		var mc = New[string, int]()
+		ch, _ := mc.Subscribe(1, nil)
+		mc.Stop()
+		_, ok := <-ch
+		assert.False(t, ok)
+
+		ch, cancel := mc.Subscribe(1, nil)
+		cancel()
+		_, ok = <-ch
+		assert.False(t, ok)
+		mc.Set("a", 1, time.Hour)
+	})
+}
+
+func TestEventType_String(t *testing.T) {
+	assert.Equal(t, EventInsert.String(), "insert")
+	assert.Equal(t, EventUpdate.String(), "update")
+	assert.Equal(t, EventDelete.String(), "delete")
+	assert.Equal(t, EventExpire.String(), "expire")
+	assert.Equal(t, EventEvict.String(), "evict")
+	assert.Equal(t, EventClear.String(), "clear")
+	assert.Equal(t, EventType(255).String(), "unknown")
+}
@@ -0,0 +1,78 @@
+package memorycache
+
+import "expvar"
+
+type (
+	expvarSnapshot struct {
+		Len     int            `json:"len"`
+		Cost    int64          `json:"cost"`
+		Buckets []expvarBucket `json:"buckets"`
+		Stats   expvarStats    `json:"stats"`
+		Config  expvarConfig   `json:"config"`
+	}
+
+	expvarBucket struct {
+		Len     int `json:"len"`
+		HeapLen int `json:"heap_len"`
+	}
+
+	expvarStats struct {
+		Hits      uint64            `json:"hits"`
+		Misses    uint64            `json:"misses"`
+		Sets      uint64            `json:"sets"`
+		Updates   uint64            `json:"updates"`
+		Rejects   uint64            `json:"rejects"`
+		HitRatio  float64           `json:"hit_ratio"`
+		Evictions map[string]uint64 `json:"evictions"`
+	}
+
+	expvarConfig struct {
+		BucketNum    int    `json:"bucket_num"`
+		BucketSize   int    `json:"bucket_size"`
+		BucketCap    int    `json:"bucket_cap"`
+		DeleteLimits int    `json:"delete_limits"`
+		MinInterval  string `json:"min_interval"`
+		MaxInterval  string `json:"max_interval"`
+	}
+)
+
+// PublishExpvar 以给定名称在expvar中发布缓存状态, 每次读取 /debug/vars 时重新计算.
+// 名称重复时会panic, 与 expvar.Publish 一致.
+// Publish the cache status in expvar under the given name, recomputed on each read of /debug/vars.
+// Panics if the name is already registered, consistent with expvar.Publish.
+func (c *MemoryCache[K, V]) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() any { return c.expvarSnapshot() }))
+}
+
+func (c *MemoryCache[K, V]) expvarSnapshot() expvarSnapshot {
+	var stats = c.Stats()
+	var snapshot = expvarSnapshot{
+		Stats: expvarStats{
+			Hits:      stats.Hits,
+			Misses:    stats.Misses,
+			Sets:      stats.Sets,
+			Updates:   stats.Updates,
+			Rejects:   stats.Rejects,
+			HitRatio:  stats.HitRatio(),
+			Evictions: make(map[string]uint64, reasonNum),
+		},
+		Config: expvarConfig{
+			BucketNum:    c.conf.BucketNum,
+			BucketSize:   c.conf.BucketSize,
+			BucketCap:    c.conf.BucketCap,
+			DeleteLimits: c.conf.DeleteLimits,
+			MinInterval:  c.conf.MinInterval.String(),
+			MaxInterval:  c.conf.MaxInterval.String(),
+		},
+	}
+	for i := 0; i < reasonNum; i++ {
+		var reason = Reason(i)
+		snapshot.Stats.Evictions[reason.String()] = stats.Evictions(reason)
+	}
+	for _, item := range c.Buckets() {
+		snapshot.Len += item.Len
+		snapshot.Cost += item.Cost
+		snapshot.Buckets = append(snapshot.Buckets, expvarBucket{Len: item.Len, HeapLen: item.HeapLen})
+	}
+	return snapshot
+}
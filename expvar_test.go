@@ -0,0 +1,43 @@
+package memorycache
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCache_PublishExpvar(t *testing.T) {
+	var mc = New[string, int](
+		WithStats(true),
+		WithBucketNum(2),
+		WithInterval(5*time.Second, 10*time.Second),
+	)
+	mc.PublishExpvar("memorycache_test")
+	mc.Set("a", 1, time.Hour)
+	mc.Get("a")
+	mc.Get("b")
+
+	var v = expvar.Get("memorycache_test")
+	assert.NotNil(t, v)
+
+	var snapshot expvarSnapshot
+	assert.NoError(t, json.Unmarshal([]byte(v.String()), &snapshot))
+	assert.Equal(t, snapshot.Len, 1)
+	assert.Equal(t, len(snapshot.Buckets), 2)
+	assert.Equal(t, snapshot.Buckets[0].Len+snapshot.Buckets[1].Len, 1)
+	assert.Equal(t, snapshot.Stats.Hits, uint64(1))
+	assert.Equal(t, snapshot.Stats.Misses, uint64(1))
+	assert.Equal(t, snapshot.Stats.Evictions["deleted"], uint64(0))
+	assert.Equal(t, snapshot.Config.BucketNum, 2)
+	assert.Equal(t, snapshot.Config.MaxInterval, "10s")
+
+	// 每次读取时重新计算
+	mc.Set("b", 1, time.Hour)
+	assert.NoError(t, json.Unmarshal([]byte(v.String()), &snapshot))
+	assert.Equal(t, snapshot.Len, 2)
+
+	assert.Panics(t, func() { mc.PublishExpvar("memorycache_test") })
+}
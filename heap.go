@@ -114,3 +114,30 @@ func (c *heap[K, V]) Down(i, n int) {
 func (c *heap[K, V]) Front() *Element[K, V] {
 	return c.List.Get(c.Data[0])
 }
+
+// Walk 按堆序访问过期时间不超过bound的元素
+// Visits elements whose expiration time does not exceed bound in heap order
+func (c *heap[K, V]) Walk(bound int64, f func(ele *Element[K, V]) bool) {
+	c.walk(0, bound, f)
+}
+
+func (c *heap[K, V]) walk(i int, bound int64, f func(ele *Element[K, V]) bool) bool {
+	if i >= c.Len() {
+		return true
+	}
+
+	var ele = c.List.Get(c.Data[i])
+	if ele.ExpireAt > bound {
+		return true
+	}
+	if !f(ele) {
+		return false
+	}
+
+	for j := (i << 2) + 1; j <= (i<<2)+4; j++ {
+		if !c.walk(j, bound, f) {
+			return false
+		}
+	}
+	return true
+}
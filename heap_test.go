@@ -70,3 +70,34 @@ func TestHeap_Delete(t *testing.T) {
 	}
 	as.ElementsMatch(list, []int64{1, 2, 3, 8, 5, 9, 7, 10})
 }
+
+func TestHeap_Walk(t *testing.T) {
+	var q = newDeque[string, int](0)
+	var h = newHeap[string, int](q, 0)
+	for i := 0; i < 1000; i++ {
+		ele := q.PushBack()
+		ele.ExpireAt = int64(rand.Intn(1000))
+		h.Push(ele)
+	}
+
+	var sum = 0
+	h.Walk(500, func(ele *Element[string, int]) bool {
+		assert.LessOrEqual(t, ele.ExpireAt, int64(500))
+		sum++
+		return true
+	})
+	var expected = 0
+	for _, addr := range h.Data {
+		if q.Get(addr).ExpireAt <= 500 {
+			expected++
+		}
+	}
+	assert.Equal(t, expected, sum)
+
+	sum = 0
+	h.Walk(500, func(ele *Element[string, int]) bool {
+		sum++
+		return sum < 10
+	})
+	assert.Equal(t, 10, sum)
+}
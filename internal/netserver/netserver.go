@@ -0,0 +1,127 @@
+// Package netserver 管理TCP服务器的监听器和连接, 协议的处理由各个服务器的连接处理函数完成.
+// Package netserver manages the listeners and connections of a TCP server; protocols are implemented by the
+// connection handler of each server.
+package netserver
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// Server 监听器和连接的集合
+// Set of listeners and connections
+type Server struct {
+	handler   func(conn net.Conn)
+	errClosed error
+
+	mu        sync.Mutex
+	closed    bool
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	wg        sync.WaitGroup
+
+	connections atomic.Uint64
+}
+
+// New 创建服务器. handler 在独立的协程中处理一个连接, 返回后连接被关闭; errClosed 是 Serve 在 Close 之后返回的错误.
+// Create a server. handler serves one connection in its own goroutine and the connection is closed when it returns;
+// errClosed is the error returned by Serve after Close.
+func New(handler func(conn net.Conn), errClosed error) *Server {
+	return &Server{
+		handler:   handler,
+		errClosed: errClosed,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenAndServe 监听TCP地址并提供服务
+// Listen on the TCP address and serve
+func (c *Server) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return c.Serve(ln)
+}
+
+// Serve 接受连接并提供服务, 直到 Close 被调用. 总是返回非nil的错误.
+// Accept connections and serve them until Close is called. Always returns a non-nil error.
+func (c *Server) Serve(ln net.Listener) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		_ = ln.Close()
+		return c.errClosed
+	}
+	c.listeners[ln] = struct{}{}
+	c.mu.Unlock()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			c.mu.Lock()
+			var closed = c.closed
+			delete(c.listeners, ln)
+			c.mu.Unlock()
+			if closed {
+				return c.errClosed
+			}
+			return err
+		}
+
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			_ = conn.Close()
+			continue
+		}
+		c.conns[conn] = struct{}{}
+		c.wg.Add(1)
+		c.mu.Unlock()
+
+		c.connections.Add(1)
+		go c.serveConn(conn)
+	}
+}
+
+// Close 关闭所有监听器和连接, 并等待连接处理协程退出
+// Close all listeners and connections, and wait for the connection goroutines to exit
+func (c *Server) Close() {
+	c.mu.Lock()
+	c.closed = true
+	for ln := range c.listeners {
+		_ = ln.Close()
+	}
+	for conn := range c.conns {
+		_ = conn.Close()
+	}
+	c.mu.Unlock()
+	c.wg.Wait()
+}
+
+func (c *Server) serveConn(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+		c.mu.Lock()
+		delete(c.conns, conn)
+		c.mu.Unlock()
+		c.wg.Done()
+	}()
+	c.handler(conn)
+}
+
+// Clients 当前的连接数量
+// Number of open connections
+func (c *Server) Clients() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.conns)
+}
+
+// Connections 累计接受的连接数量
+// Total number of accepted connections
+func (c *Server) Connections() uint64 {
+	return c.connections.Load()
+}
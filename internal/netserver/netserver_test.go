@@ -0,0 +1,70 @@
+package netserver
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errClosed = errors.New("test: server closed")
+
+func TestServer(t *testing.T) {
+	t.Run("serve", func(t *testing.T) {
+		var srv = New(func(conn net.Conn) {
+			var buf = make([]byte, 4)
+			n, _ := conn.Read(buf)
+			_, _ = conn.Write(buf[:n])
+		}, errClosed)
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		var done = make(chan error, 1)
+		go func() { done <- srv.Serve(ln) }()
+
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		assert.NoError(t, err)
+		defer conn.Close()
+		_, _ = conn.Write([]byte("ping"))
+		var buf = make([]byte, 4)
+		_, err = conn.Read(buf)
+		assert.NoError(t, err)
+		assert.Equal(t, string(buf), "ping")
+		assert.Equal(t, srv.Connections(), uint64(1))
+
+		// 处理函数返回后连接被关闭
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, err = conn.Read(buf)
+		assert.Error(t, err)
+		assert.Eventually(t, func() bool { return srv.Clients() == 0 }, time.Second, 10*time.Millisecond)
+
+		srv.Close()
+		assert.ErrorIs(t, <-done, errClosed)
+	})
+
+	t.Run("close", func(t *testing.T) {
+		var started = make(chan struct{})
+		var srv = New(func(conn net.Conn) {
+			close(started)
+			_, _ = conn.Read(make([]byte, 1))
+		}, errClosed)
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		go func() { _ = srv.Serve(ln) }()
+
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		assert.NoError(t, err)
+		defer conn.Close()
+		<-started
+		assert.Equal(t, srv.Clients(), 1)
+
+		// Close 关闭连接并等待处理函数返回
+		srv.Close()
+		assert.Equal(t, srv.Clients(), 0)
+
+		ln, err = net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		assert.ErrorIs(t, srv.Serve(ln), errClosed)
+	})
+}
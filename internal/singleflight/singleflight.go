@@ -0,0 +1,118 @@
+package singleflight
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// 执行fn时发生的panic, 作为错误返回给等待同一调用的协程
+type panicError struct {
+	value any
+	stack []byte
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("singleflight: panic: %v\n\n%s", e.value, e.stack)
+}
+
+type call[V any] struct {
+	done     chan struct{}
+	val      V
+	err      error
+	expireAt time.Time // 错误结果的缓存截止时间
+}
+
+// Group 合并同一个键的并发调用, 同一时刻只有一个调用在执行.
+// Group merges concurrent calls for the same key, only one call is executed at a time.
+type Group[K comparable, V any] struct {
+	mu     sync.Mutex
+	calls  map[K]*call[V]
+	errTTL time.Duration
+}
+
+// New 创建调用组. errTTL>0时, 失败结果会被缓存errTTL时长.
+// Create a call group. When errTTL>0, failed results are cached for errTTL.
+func New[K comparable, V any](errTTL time.Duration) *Group[K, V] {
+	return &Group[K, V]{calls: make(map[K]*call[V]), errTTL: errTTL}
+}
+
+// Do 执行并返回fn的结果. 如果已有相同键的调用在执行, 等待其结果.
+// ctx只控制等待, 不会中断正在执行的fn.
+// Execute and return the result of fn. If a call with the same key is in flight, wait for its result.
+// ctx only controls the waiting and does not interrupt fn.
+func (c *Group[K, V]) Do(ctx context.Context, key K, fn func() (V, error)) (v V, err error) {
+	c.mu.Lock()
+	if item, ok := c.calls[key]; ok && !c.expired(item) {
+		c.mu.Unlock()
+		select {
+		case <-item.done:
+			return item.val, item.err
+		case <-ctx.Done():
+			return v, ctx.Err()
+		}
+	}
+
+	var item = &call[V]{done: make(chan struct{})}
+	c.calls[key] = item
+	c.mu.Unlock()
+
+	c.doCall(key, item, fn)
+	return item.val, item.err
+}
+
+// 执行fn. fn发生panic时, 等待的协程收到panicError, panic在执行fn的协程中重新抛出, 且结果不被缓存.
+func (c *Group[K, V]) doCall(key K, item *call[V], fn func() (V, error)) {
+	var normalReturn = false
+	var recovered any
+	defer func() {
+		c.mu.Lock()
+		if normalReturn && item.err != nil && c.errTTL > 0 {
+			item.expireAt = time.Now().Add(c.errTTL)
+		} else if c.calls[key] == item {
+			delete(c.calls, key)
+		}
+		c.mu.Unlock()
+		close(item.done)
+
+		// recovered为nil时是runtime.Goexit, 继续退出协程即可
+		if recovered != nil {
+			panic(recovered)
+		}
+	}()
+
+	func() {
+		defer func() {
+			if !normalReturn {
+				recovered = recover()
+				item.err = &panicError{value: recovered, stack: debug.Stack()}
+			}
+		}()
+		item.val, item.err = fn()
+		normalReturn = true
+	}()
+}
+
+// 调用已完成且错误缓存已过期
+func (c *Group[K, V]) expired(item *call[V]) bool {
+	select {
+	case <-item.done:
+		return time.Now().After(item.expireAt)
+	default:
+		return false
+	}
+}
+
+// Purge 清理过期的错误缓存
+// Purge expired error caches
+func (c *Group[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, item := range c.calls {
+		if c.expired(item) {
+			delete(c.calls, k)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package singleflight
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroup_Do(t *testing.T) {
+	t.Run("merge", func(t *testing.T) {
+		var g = New[string, int](0)
+		var calls atomic.Int64
+		var wg = &sync.WaitGroup{}
+		var start = make(chan struct{})
+		wg.Add(100)
+		for i := 0; i < 100; i++ {
+			go func() {
+				defer wg.Done()
+				v, err := g.Do(context.Background(), "a", func() (int, error) {
+					calls.Add(1)
+					<-start
+					return 1, nil
+				})
+				assert.NoError(t, err)
+				assert.Equal(t, v, 1)
+			}()
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(start)
+		wg.Wait()
+		assert.Equal(t, calls.Load(), int64(1))
+		assert.Equal(t, len(g.calls), 0)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		var g = New[string, int](0)
+		var calls = 0
+		for i := 0; i < 3; i++ {
+			_, err := g.Do(context.Background(), "a", func() (int, error) {
+				calls++
+				return 0, errors.New("test")
+			})
+			assert.Error(t, err)
+		}
+		assert.Equal(t, calls, 3)
+	})
+
+	t.Run("error ttl", func(t *testing.T) {
+		var g = New[string, int](50 * time.Millisecond)
+		var calls = 0
+		for i := 0; i < 3; i++ {
+			_, err := g.Do(context.Background(), "a", func() (int, error) {
+				calls++
+				return 0, errors.New("test")
+			})
+			assert.Error(t, err)
+		}
+		assert.Equal(t, calls, 1)
+
+		time.Sleep(100 * time.Millisecond)
+		g.Purge()
+		assert.Equal(t, len(g.calls), 0)
+		v, err := g.Do(context.Background(), "a", func() (int, error) {
+			calls++
+			return 1, nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, v, 1)
+		assert.Equal(t, calls, 2)
+	})
+
+	t.Run("context", func(t *testing.T) {
+		var g = New[string, int](0)
+		var start = make(chan struct{})
+		defer close(start)
+		go g.Do(context.Background(), "a", func() (int, error) {
+			<-start
+			return 1, nil
+		})
+		time.Sleep(20 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := g.Do(ctx, "a", func() (int, error) { return 2, nil })
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+	t.Run("panic", func(t *testing.T) {
+		var g = New[string, int](time.Minute)
+		var start = make(chan struct{})
+		go func() {
+			defer func() { _ = recover() }()
+			_, _ = g.Do(context.Background(), "a", func() (int, error) {
+				<-start
+				panic("test")
+			})
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		var done = make(chan error, 1)
+		go func() {
+			_, err := g.Do(context.Background(), "a", func() (int, error) { return 1, nil })
+			done <- err
+		}()
+		time.Sleep(20 * time.Millisecond)
+		close(start)
+		var err = <-done
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "panic: test")
+
+		// panic在执行fn的协程中重新抛出, 且不被缓存
+		assert.PanicsWithValue(t, "test", func() {
+			_, _ = g.Do(context.Background(), "b", func() (int, error) { panic("test") })
+		})
+		v, err := g.Do(context.Background(), "b", func() (int, error) { return 1, nil })
+		assert.NoError(t, err)
+		assert.Equal(t, v, 1)
+	})
+}
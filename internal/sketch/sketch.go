@@ -0,0 +1,127 @@
+package sketch
+
+import "github.com/lxzan/memorycache/internal/utils"
+
+const (
+	depth      = 4  // 计数器行数
+	maxCounter = 15 // 计数器上限
+)
+
+var seeds = [depth]uint64{0xc3a5c85c97cb3127, 0xb492b66fbe98f273, 0x9ae16a3b2f90404f, 0xcbf29ce484222325}
+
+// Sketch 带门卫布隆过滤器的Count-Min Sketch, 用于估计键的访问频率.
+// 第一次出现的键只记录在门卫中, 避免只访问一次的键占用计数器.
+// 计数器总增量达到采样数后, 所有计数减半并清空门卫, 使旧的频率逐渐衰减.
+// A Count-Min Sketch with a doorkeeper bloom filter, used to estimate the access frequency of keys.
+// Keys seen for the first time are only recorded in the doorkeeper, so one-hit wonders do not occupy counters.
+// Once the number of increments reaches the sample size, all counters are halved and the doorkeeper is cleared,
+// so that old frequencies decay gradually.
+type Sketch struct {
+	rows       [depth][]uint8
+	mask       uint64
+	door       []uint64
+	doorMask   uint64
+	additions  int
+	sampleSize int
+}
+
+// New 创建频率估计器, capacity为预期的键数量
+// Create a frequency estimator, capacity is the expected number of keys
+func New(capacity int) *Sketch {
+	var width = utils.ToBinaryNumber(uint64(capacity))
+	if width < 16 {
+		width = 16
+	}
+
+	var c = &Sketch{
+		mask:       width - 1,
+		door:       make([]uint64, width/8),
+		doorMask:   width*8 - 1,
+		sampleSize: 10 * int(width),
+	}
+	for i := range c.rows {
+		c.rows[i] = make([]uint8, width)
+	}
+	return c
+}
+
+func mix(hash, seed uint64) uint64 {
+	var x = (hash + seed) * 0x9e3779b97f4a7c15
+	return x ^ (x >> 32)
+}
+
+// Increment 增加一次访问计数
+// Increase the access count once
+func (c *Sketch) Increment(hash uint64) {
+	if !c.admitDoor(hash) {
+		return
+	}
+
+	var added = false
+	for i := range c.rows {
+		var index = mix(hash, seeds[i]) & c.mask
+		if c.rows[i][index] < maxCounter {
+			c.rows[i][index]++
+			added = true
+		}
+	}
+
+	if added {
+		c.additions++
+		if c.additions >= c.sampleSize {
+			c.Reset()
+		}
+	}
+}
+
+// Estimate 估计访问频率
+// Estimated access frequency
+func (c *Sketch) Estimate(hash uint64) int {
+	var min uint8 = maxCounter
+	for i := range c.rows {
+		if v := c.rows[i][mix(hash, seeds[i])&c.mask]; v < min {
+			min = v
+		}
+	}
+
+	var n = int(min)
+	if c.containsDoor(hash) {
+		n++
+	}
+	return n
+}
+
+// Reset 计数减半并清空门卫
+// Halve the counters and clear the doorkeeper
+func (c *Sketch) Reset() {
+	for i := range c.rows {
+		for j := range c.rows[i] {
+			c.rows[i][j] >>= 1
+		}
+	}
+	for i := range c.door {
+		c.door[i] = 0
+	}
+	c.additions /= 2
+}
+
+func (c *Sketch) doorBits(hash uint64) (uint64, uint64) {
+	var x = mix(hash, seeds[0]^seeds[1])
+	return x & c.doorMask, (x >> 32) & c.doorMask
+}
+
+func (c *Sketch) containsDoor(hash uint64) bool {
+	var i, j = c.doorBits(hash)
+	return c.door[i>>6]&(1<<(i&63)) != 0 && c.door[j>>6]&(1<<(j&63)) != 0
+}
+
+// 记录到门卫中, 返回之前是否已经存在
+func (c *Sketch) admitDoor(hash uint64) bool {
+	if c.containsDoor(hash) {
+		return true
+	}
+	var i, j = c.doorBits(hash)
+	c.door[i>>6] |= 1 << (i & 63)
+	c.door[j>>6] |= 1 << (j & 63)
+	return false
+}
@@ -0,0 +1,51 @@
+package sketch
+
+import (
+	"testing"
+
+	"github.com/lxzan/memorycache/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSketch(t *testing.T) {
+	t.Run("estimate", func(t *testing.T) {
+		var s = New(1000)
+		assert.Equal(t, s.Estimate(1), 0)
+		s.Increment(1)
+		assert.Equal(t, s.Estimate(1), 1)
+		for i := 0; i < 5; i++ {
+			s.Increment(1)
+		}
+		assert.Equal(t, s.Estimate(1), 6)
+		for i := 0; i < 100; i++ {
+			s.Increment(1)
+		}
+		assert.Equal(t, s.Estimate(1), maxCounter+1)
+	})
+
+	t.Run("frequency order", func(t *testing.T) {
+		var s = New(1000)
+		for i := 0; i < 1000; i++ {
+			s.Increment(utils.Numeric.Uint64())
+		}
+		for i := 0; i < 8; i++ {
+			s.Increment(100)
+		}
+		s.Increment(200)
+		assert.Greater(t, s.Estimate(100), s.Estimate(200))
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		var s = New(16)
+		for i := 0; i < 9; i++ {
+			s.Increment(1)
+		}
+		s.Reset()
+		assert.Equal(t, s.Estimate(1), 4)
+
+		for i := 0; i < s.sampleSize*2; i++ {
+			s.Increment(utils.Numeric.Uint64())
+		}
+		assert.Less(t, s.additions, s.sampleSize)
+	})
+}
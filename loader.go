@@ -0,0 +1,119 @@
+package memorycache
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// GetOrLoad 查询缓存. 如果不存在, 调用loader加载并写入缓存.
+// 同一个键的并发加载会被合并为一次loader调用, 加载错误会返回给所有等待者.
+// ctx用于控制等待; 合并的加载使用第一个调用者的ctx.
+// Query the cache. If the key does not exist, call loader to load it and write it to the cache.
+// Concurrent loads of the same key are merged into a single loader call, and load errors are returned to all waiters.
+// ctx controls the waiting; a merged load uses the ctx of the first caller.
+func (c *MemoryCache[K, V]) GetOrLoad(ctx context.Context, key K, loader LoaderFunc[K, V]) (v V, err error) {
+	if v, ok := c.Get(key); ok {
+		return v, nil
+	}
+
+	return c.group.Do(ctx, key, func() (V, error) {
+		// 上一次加载可能刚刚完成并写入了缓存
+		if v, ok := c.get(key, false); ok {
+			return v, nil
+		}
+
+		value, exp, err := loader(ctx, key)
+		if err != nil {
+			return value, err
+		}
+		c.Set(key, value, exp)
+		return value, nil
+	})
+}
+
+// 提前刷新任务, version为调度刷新时元素的写入版本
+type refreshTask[K comparable] struct {
+	key     K
+	version uint64
+}
+
+// 在后台重新加载键, 同一个键的并发刷新会被合并. 刷新使用单独的调用组, 不会读到 GetOrLoad 缓存的加载错误.
+// 加载函数的panic被视为加载失败, 不会使进程崩溃.
+func (c *MemoryCache[K, V]) refresh(task refreshTask[K]) {
+	go func() {
+		var failed = true
+		defer func() {
+			_ = recover()
+			if failed {
+				c.retryRefresh(task)
+			}
+		}()
+
+		_, err := c.refresher.Do(c.ctx, task.key, func() (V, error) {
+			value, exp, err := c.loader(c.ctx, task.key)
+			if err == nil {
+				c.reload(task, value, exp)
+			}
+			return value, err
+		})
+		failed = err != nil
+	}()
+}
+
+// 刷新失败, 至少等待 RefreshRetryInterval 之后, 下一次访问或后台检查才会再次尝试刷新.
+// 元素在刷新期间被重新写入时, 写入已经重置了刷新时间.
+func (c *MemoryCache[K, V]) retryRefresh(task refreshTask[K]) {
+	var b = c.getBucket(task.key)
+	b.Lock()
+	defer b.Unlock()
+
+	if ele := b.Find(b.hashcode, task.key); ele != nil && ele.version == task.version {
+		ele.refreshAt = c.getTimestamp() + c.conf.RefreshRetryInterval.Milliseconds()
+	}
+}
+
+// 写入重新加载的结果. 元素已被删除或在调度刷新之后被重新写入时丢弃结果, 以免覆盖更新的值.
+func (c *MemoryCache[K, V]) reload(task refreshTask[K], value V, exp time.Duration) {
+	var key = task.key
+	var b = c.getBucket(key)
+	b.Lock()
+	defer b.Unlock()
+
+	ele, ok := c.fetch(b, key)
+	if !ok || ele.version != task.version {
+		return
+	}
+
+	// 保持软过期和过期之间的宽限期
+	var staleAt, expireAt = c.getExp(exp), c.getExp(exp)
+	if ele.StaleAt < ele.ExpireAt && exp > 0 && ele.ExpireAt != math.MaxInt64 {
+		expireAt = staleAt + ele.ExpireAt - ele.StaleAt
+	} else if ele.StaleAt < ele.ExpireAt {
+		expireAt = math.MaxInt64
+	}
+
+	var old = ele.Value
+	ele.Value, ele.StaleAt, ele.refreshAt = value, staleAt, c.getRefreshAt(exp)
+	b.stamp(ele)
+	if c.loader != nil && staleAt < expireAt && staleAt < ele.refreshAt {
+		ele.refreshAt = staleAt
+	}
+	b.Heap.UpdateTTL(ele, expireAt)
+	b.updateWindow(ele)
+	if !b.Resize(ele, c.weigh(key, value)) {
+		return
+	}
+	b.aof.set(ele)
+	if b.hub.enabled() {
+		b.hub.publish(Event[K, V]{Type: EventUpdate, Key: key, OldValue: old, NewValue: value})
+	}
+}
+
+// 访问元素时检查是否需要在后台重新加载
+func (c *MemoryCache[K, V]) checkRefresh(ele *Element[K, V]) {
+	if ele.refreshAt <= c.getTimestamp() {
+		ele.refreshAt = math.MaxInt64
+		c.refresh(refreshTask[K]{key: ele.Key, version: ele.version})
+	}
+}
@@ -0,0 +1,270 @@
+package memorycache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCache_GetOrLoad(t *testing.T) {
+	t.Run("hit", func(t *testing.T) {
+		var mc = New[string, int]()
+		mc.Set("a", 1, time.Hour)
+		v, err := mc.GetOrLoad(context.Background(), "a", func(ctx context.Context, key string) (int, time.Duration, error) {
+			t.Fail()
+			return 0, 0, nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, v, 1)
+	})
+
+	t.Run("miss", func(t *testing.T) {
+		var mc = New[string, int](WithCachedTime(false))
+		v, err := mc.GetOrLoad(context.Background(), "a", func(ctx context.Context, key string) (int, time.Duration, error) {
+			return 2, 50 * time.Millisecond, nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, v, 2)
+
+		v, ok := mc.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, v, 2)
+
+		time.Sleep(100 * time.Millisecond)
+		_, ok = mc.Get("a")
+		assert.False(t, ok)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		var mc = New[string, int]()
+		var calls atomic.Int64
+		var wg = &sync.WaitGroup{}
+		wg.Add(100)
+		for i := 0; i < 100; i++ {
+			go func() {
+				defer wg.Done()
+				v, err := mc.GetOrLoad(context.Background(), "a", func(ctx context.Context, key string) (int, time.Duration, error) {
+					calls.Add(1)
+					time.Sleep(50 * time.Millisecond)
+					return 3, time.Hour, nil
+				})
+				assert.NoError(t, err)
+				assert.Equal(t, v, 3)
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, calls.Load(), int64(1))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		var mc = New[string, int]()
+		var calls = 0
+		var loader = func(ctx context.Context, key string) (int, time.Duration, error) {
+			calls++
+			return 0, time.Hour, errors.New("test")
+		}
+		_, err := mc.GetOrLoad(context.Background(), "a", loader)
+		assert.Error(t, err)
+		_, err = mc.GetOrLoad(context.Background(), "a", loader)
+		assert.Error(t, err)
+		assert.Equal(t, calls, 2)
+		assert.Equal(t, mc.Len(), 0)
+	})
+
+	t.Run("error ttl", func(t *testing.T) {
+		var mc = New[string, int](WithLoaderErrorTTL(time.Hour))
+		var calls = 0
+		var loader = func(ctx context.Context, key string) (int, time.Duration, error) {
+			calls++
+			return 0, time.Hour, errors.New("test")
+		}
+		_, err := mc.GetOrLoad(context.Background(), "a", loader)
+		assert.Error(t, err)
+		_, err = mc.GetOrLoad(context.Background(), "a", loader)
+		assert.Error(t, err)
+		assert.Equal(t, calls, 1)
+		assert.Equal(t, mc.Len(), 0)
+	})
+}
+
+func TestMemoryCache_RefreshAhead(t *testing.T) {
+	t.Run("get", func(t *testing.T) {
+		var calls atomic.Int64
+		var mc = New[string, int](
+			WithCachedTime(false),
+			WithRefreshAhead(func(ctx context.Context, key string) (int, time.Duration, error) {
+				return int(calls.Add(1)) + 1, 200 * time.Millisecond, nil
+			}, 0.5),
+		)
+		mc.Set("a", 1, 200*time.Millisecond)
+
+		v, _ := mc.Get("a")
+		assert.Equal(t, v, 1)
+		time.Sleep(50 * time.Millisecond)
+		v, _ = mc.Get("a")
+		assert.Equal(t, v, 1)
+		assert.Equal(t, calls.Load(), int64(0))
+
+		time.Sleep(100 * time.Millisecond)
+		v, ok := mc.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, v, 1)
+
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, calls.Load(), int64(1))
+		time.Sleep(100 * time.Millisecond)
+		v, ok = mc.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, v, 2)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		var calls atomic.Int64
+		var mc = New[string, int](
+			WithCachedTime(false),
+			WithRefreshRetryInterval(10*time.Millisecond),
+			WithRefreshAhead(func(ctx context.Context, key string) (int, time.Duration, error) {
+				calls.Add(1)
+				return 0, 0, errors.New("test")
+			}, 1),
+		)
+		mc.Set("a", 1, 200*time.Millisecond)
+		mc.Get("a")
+		time.Sleep(50 * time.Millisecond)
+		v, ok := mc.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, v, 1)
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, calls.Load(), int64(2))
+	})
+
+	t.Run("retry interval", func(t *testing.T) {
+		var calls atomic.Int64
+		var mc = New[string, int](
+			WithCachedTime(false),
+			WithRefreshAhead(func(ctx context.Context, key string) (int, time.Duration, error) {
+				calls.Add(1)
+				return 0, 0, errors.New("test")
+			}, 1),
+		)
+		mc.Set("a", 1, time.Hour)
+		mc.Get("a")
+		time.Sleep(50 * time.Millisecond)
+		for i := 0; i < 10; i++ {
+			mc.Get("a")
+		}
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, calls.Load(), int64(1))
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		var calls atomic.Int64
+		var mc = New[string, int](
+			WithCachedTime(false),
+			WithRefreshRetryInterval(10*time.Millisecond),
+			WithRefreshAhead(func(ctx context.Context, key string) (int, time.Duration, error) {
+				if calls.Add(1) == 1 {
+					panic("test")
+				}
+				return 2, time.Hour, nil
+			}, 1),
+		)
+		mc.Set("a", 1, time.Hour)
+		mc.Get("a")
+		time.Sleep(50 * time.Millisecond)
+		mc.Get("a")
+		time.Sleep(50 * time.Millisecond)
+		v, _ := mc.Get("a")
+		assert.Equal(t, v, 2)
+		assert.Equal(t, calls.Load(), int64(2))
+	})
+
+	t.Run("overwritten", func(t *testing.T) {
+		var start = make(chan struct{})
+		var mc = New[string, int](
+			WithCachedTime(false),
+			WithRefreshAhead(func(ctx context.Context, key string) (int, time.Duration, error) {
+				<-start
+				return 2, time.Hour, nil
+			}, 1),
+		)
+		mc.Set("a", 1, time.Hour)
+		mc.Get("a")
+		mc.Set("a", 3, time.Hour)
+		close(start)
+		time.Sleep(50 * time.Millisecond)
+		v, _, _ := mc.Peek("a")
+		assert.Equal(t, v, 3)
+	})
+
+	t.Run("error ttl", func(t *testing.T) {
+		var calls atomic.Int64
+		var mc = New[string, int](
+			WithCachedTime(false),
+			WithLoaderErrorTTL(time.Hour),
+			WithRefreshRetryInterval(10*time.Millisecond),
+			WithRefreshAhead(func(ctx context.Context, key string) (int, time.Duration, error) {
+				if calls.Add(1) == 1 {
+					return 0, 0, errors.New("test")
+				}
+				return 2, time.Hour, nil
+			}, 1),
+		)
+		mc.Set("a", 1, time.Hour)
+		mc.Get("a")
+		time.Sleep(50 * time.Millisecond)
+
+		// 刷新不共享缓存的加载错误, 加载恢复后可以再次刷新
+		v, _ := mc.Get("a")
+		assert.Equal(t, v, 1)
+		time.Sleep(50 * time.Millisecond)
+		v, _ = mc.Get("a")
+		assert.Equal(t, v, 2)
+		assert.Equal(t, calls.Load(), int64(2))
+	})
+
+	t.Run("janitor", func(t *testing.T) {
+		var mc = New[string, int](
+			WithCachedTime(false),
+			WithInterval(10*time.Millisecond, 10*time.Millisecond),
+			WithRefreshAhead(func(ctx context.Context, key string) (int, time.Duration, error) {
+				return 2, time.Hour, nil
+			}, 0.5),
+		)
+		mc.Set("a", 1, 200*time.Millisecond)
+		mc.Set("b", 1, time.Hour)
+		mc.Set("c", 1, -1)
+
+		time.Sleep(300 * time.Millisecond)
+		v, ok := mc.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, v, 2)
+		v, _ = mc.Get("b")
+		assert.Equal(t, v, 1)
+		v, _ = mc.Get("c")
+		assert.Equal(t, v, 1)
+	})
+
+	t.Run("deleted", func(t *testing.T) {
+		var start = make(chan struct{})
+		var mc = New[string, int](
+			WithCachedTime(false),
+			WithRefreshAhead(func(ctx context.Context, key string) (int, time.Duration, error) {
+				<-start
+				return 2, time.Hour, nil
+			}, 1),
+		)
+		mc.Set("a", 1, time.Hour)
+		mc.Get("a")
+		mc.Delete("a")
+		close(start)
+		time.Sleep(50 * time.Millisecond)
+		_, ok := mc.Get("a")
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,528 @@
+package memcached
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lxzan/memorycache"
+)
+
+// 兼容的memcached版本号
+const version = "1.6.0"
+
+var errLineTooLong = errors.New("memcached: line too long")
+
+// 连接状态
+type client struct {
+	server  *Server
+	r       *bufio.Reader
+	w       *bufio.Writer
+	noreply bool
+	quit    bool
+}
+
+// 命令处理函数, args不包含命令名. 返回错误时关闭连接.
+type handler func(c *client, name string, args [][]byte) error
+
+// 命令表, noreply表示命令接受末尾的noreply参数
+var commands = map[string]struct {
+	noreply bool
+	fn      handler
+}{
+	"get":       {false, cmdGet},
+	"gets":      {false, cmdGet},
+	"gat":       {false, cmdGet},
+	"gats":      {false, cmdGet},
+	"set":       {true, cmdStore},
+	"add":       {true, cmdStore},
+	"replace":   {true, cmdStore},
+	"append":    {true, cmdStore},
+	"prepend":   {true, cmdStore},
+	"cas":       {true, cmdStore},
+	"delete":    {true, cmdDelete},
+	"incr":      {true, cmdIncr},
+	"decr":      {true, cmdIncr},
+	"touch":     {true, cmdTouch},
+	"flush_all": {true, cmdFlushAll},
+	"stats":     {false, cmdStats},
+	"version":   {false, cmdVersion},
+	"verbosity": {true, cmdVerbosity},
+	"quit":      {false, cmdQuit},
+}
+
+// 读取一行命令, 不包含行尾的换行符
+func (c *client) readLine() ([]byte, error) {
+	line, err := c.r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		var buf = append([]byte(nil), line...)
+		for errors.Is(err, bufio.ErrBufferFull) && len(buf) <= maxLineSize {
+			line, err = c.r.ReadSlice('\n')
+			buf = append(buf, line...)
+		}
+		if len(buf) > maxLineSize {
+			return nil, errLineTooLong
+		}
+		line = buf
+	}
+	if err != nil {
+		return nil, err
+	}
+	line = line[:len(line)-1]
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return line, nil
+}
+
+func (c *client) execute(line []byte) error {
+	c.noreply = false
+	var args = bytes.Fields(line)
+	if len(args) == 0 {
+		c.reply("ERROR")
+		return nil
+	}
+	var name = string(args[0])
+	cmd, ok := commands[name]
+	if !ok {
+		c.reply("ERROR")
+		return nil
+	}
+	args = args[1:]
+	if n := len(args); cmd.noreply && n > 0 && string(args[n-1]) == "noreply" {
+		c.noreply = true
+		args = args[:n-1]
+	}
+	return cmd.fn(c, name, args)
+}
+
+// 写出一行回复, noreply模式下忽略
+func (c *client) reply(s string) {
+	if c.noreply {
+		return
+	}
+	_, _ = c.w.WriteString(s)
+	_, _ = c.w.WriteString("\r\n")
+}
+
+func (c *client) errFormat() { c.reply("CLIENT_ERROR bad command line format") }
+
+func (c *client) cache() *memorycache.MemoryCache[string, []byte] {
+	return c.server.cache
+}
+
+// 键的长度不能超过250, 不能包含控制字符
+func validKey(key []byte) bool {
+	if len(key) == 0 || len(key) > 250 {
+		return false
+	}
+	for _, ch := range key {
+		if ch <= ' ' || ch == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+func parseUint(p []byte, bitSize int) (uint64, bool) {
+	n, err := strconv.ParseUint(string(p), 10, bitSize)
+	return n, err == nil
+}
+
+func parseInt(p []byte) (int64, bool) {
+	n, err := strconv.ParseInt(string(p), 10, 64)
+	return n, err == nil
+}
+
+// 原子地刷新过期时间并返回值和元数据, 值和CAS唯一值不变. expired为true时删除键.
+func (c *client) touch(key string, d time.Duration, expired bool) (value []byte, m meta, exist bool) {
+	c.cache().Compute(key, func(old []byte, ok bool) ([]byte, time.Duration, memorycache.ComputeOp) {
+		switch {
+		case !ok:
+			return old, 0, memorycache.ComputeKeep
+		case expired:
+			exist = true
+			return nil, 0, memorycache.ComputeDelete
+		default:
+			value, m, exist = old, c.server.ensure(key, old), true
+			return old, d, memorycache.ComputeSet
+		}
+	})
+	return value, m, exist
+}
+
+// 原子地读取值和元数据, 值没有CAS唯一值时分配一个
+func (c *client) load(key string) (value []byte, m meta, exist bool) {
+	c.cache().Compute(key, func(old []byte, ok bool) ([]byte, time.Duration, memorycache.ComputeOp) {
+		if ok {
+			value, m, exist = old, c.server.ensure(key, old), true
+		}
+		return old, 0, memorycache.ComputeKeep
+	})
+	return value, m, exist
+}
+
+// get|gets <key>*, gat|gats <exptime> <key>*
+func cmdGet(c *client, name string, args [][]byte) error {
+	var s = c.server
+	var touch = name[:3] == "gat"
+	var withCAS = name[len(name)-1] == 's'
+
+	var d time.Duration
+	var expired bool
+	if touch {
+		if len(args) == 0 {
+			c.reply("ERROR")
+			return nil
+		}
+		exptime, ok := parseInt(args[0])
+		if !ok {
+			c.reply("CLIENT_ERROR invalid exptime argument")
+			return nil
+		}
+		d, expired = toTTL(exptime, time.Now())
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		c.reply("ERROR")
+		return nil
+	}
+	for _, item := range args {
+		if !validKey(item) {
+			c.errFormat()
+			return nil
+		}
+	}
+
+	for _, item := range args {
+		var key = string(item)
+		s.cmdGet.Add(1)
+		var value []byte
+		var m meta
+		var ok bool
+		if touch {
+			s.cmdTouch.Add(1)
+			value, m, ok = c.touch(key, d, expired)
+			ok = ok && !expired
+		} else if value, ok = c.cache().Get(key); ok {
+			var found bool
+			// 其他途径写入的值在锁内分配CAS唯一值
+			if m, found = s.meta.get(key, value); !found && withCAS {
+				value, m, ok = c.load(key)
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		_, _ = c.w.WriteString("VALUE ")
+		_, _ = c.w.WriteString(key)
+		_, _ = c.w.WriteString(" ")
+		_, _ = c.w.WriteString(strconv.FormatUint(uint64(m.flags), 10))
+		_, _ = c.w.WriteString(" ")
+		_, _ = c.w.WriteString(strconv.Itoa(len(value)))
+		if withCAS {
+			_, _ = c.w.WriteString(" ")
+			_, _ = c.w.WriteString(strconv.FormatUint(m.unique, 10))
+		}
+		_, _ = c.w.WriteString("\r\n")
+		_, _ = c.w.Write(value)
+		_, _ = c.w.WriteString("\r\n")
+	}
+	c.reply("END")
+	return nil
+}
+
+// <command> <key> <flags> <exptime> <bytes> [<cas unique>] [noreply]\r\n<data block>\r\n
+func cmdStore(c *client, name string, args [][]byte) error {
+	var s = c.server
+	var argc = 4
+	if name == "cas" {
+		argc = 5
+	}
+	if len(args) != argc || !validKey(args[0]) {
+		c.errFormat()
+		return nil
+	}
+	flags, ok1 := parseUint(args[1], 32)
+	exptime, ok2 := parseInt(args[2])
+	size, ok3 := parseInt(args[3])
+	var unique uint64
+	var ok4 = true
+	if name == "cas" {
+		unique, ok4 = parseUint(args[4], 64)
+	}
+	if !ok1 || !ok2 || !ok3 || !ok4 || size < 0 {
+		c.errFormat()
+		return nil
+	}
+
+	// 超出长度限制的数据块被丢弃
+	if size > maxItemSize {
+		if _, err := c.r.Discard(int(size) + 2); err != nil {
+			return err
+		}
+		c.noreply = false
+		c.reply("SERVER_ERROR object too large for cache")
+		return nil
+	}
+	// 数据块和行尾一起读入, 值的容量至少为2, 满足 sameValue 的要求
+	var item = make([]byte, size+2)
+	if _, err := io.ReadFull(c.r, item); err != nil {
+		return err
+	}
+	var end = int(size)
+	if item[end] != '\r' || item[end+1] != '\n' {
+		// 丢弃数据块之后到行尾的内容
+		if item[end+1] != '\n' {
+			if _, err := c.readLine(); err != nil {
+				return err
+			}
+		}
+		c.noreply = false
+		c.reply("CLIENT_ERROR bad data chunk")
+		return nil
+	}
+	item = item[:end]
+	s.cmdSet.Add(1)
+
+	var key = string(args[0])
+	var d, expired = toTTL(exptime, time.Now())
+	var reply = "STORED"
+	_, stored := c.cache().Compute(key, func(old []byte, exist bool) ([]byte, time.Duration, memorycache.ComputeOp) {
+		var op = memorycache.ComputeSet
+		switch name {
+		case "add", "replace":
+			if exist != (name == "replace") {
+				reply = "NOT_STORED"
+				return old, 0, memorycache.ComputeKeep
+			}
+		case "append", "prepend":
+			if !exist {
+				reply = "NOT_STORED"
+				return old, 0, memorycache.ComputeKeep
+			}
+			// 保留原有的标志位和过期时间
+			var m, _ = s.meta.get(key, old)
+			var buf = newValue(len(old) + int(size))
+			if name == "append" {
+				buf = append(append(buf, old...), item...)
+			} else {
+				buf = append(append(buf, item...), old...)
+			}
+			flags, item, expired, op = uint64(m.flags), buf, false, memorycache.ComputeUpdate
+		case "cas":
+			if !exist {
+				reply = "NOT_FOUND"
+				return old, 0, memorycache.ComputeKeep
+			}
+			// 没有CAS唯一值的值不可能被客户端读取到唯一值
+			if m, ok := s.meta.get(key, old); !ok || m.unique != unique {
+				reply = "EXISTS"
+				return old, 0, memorycache.ComputeKeep
+			}
+		}
+		if expired {
+			return nil, 0, memorycache.ComputeDelete
+		}
+		return s.stamp(key, item, uint32(flags)), d, op
+	})
+
+	// 写入被准入策略或开销上限拒绝
+	if reply == "STORED" && !expired && !stored {
+		s.meta.remove(key, item)
+		reply = "SERVER_ERROR out of memory storing object"
+	}
+	c.reply(reply)
+	return nil
+}
+
+// delete <key> [0] [noreply]
+func cmdDelete(c *client, name string, args [][]byte) error {
+	if len(args) == 0 || len(args) > 2 || !validKey(args[0]) {
+		c.errFormat()
+		return nil
+	}
+	if len(args) == 2 && string(args[1]) != "0" {
+		c.reply("CLIENT_ERROR bad command line format.  Usage: delete <key> [noreply]")
+		return nil
+	}
+
+	if c.cache().Delete(string(args[0])) {
+		c.reply("DELETED")
+	} else {
+		c.reply("NOT_FOUND")
+	}
+	return nil
+}
+
+// incr|decr <key> <value> [noreply]. incr溢出时回绕, decr最小减到0.
+func cmdIncr(c *client, name string, args [][]byte) error {
+	if len(args) != 2 || !validKey(args[0]) {
+		c.errFormat()
+		return nil
+	}
+	delta, ok := parseUint(args[1], 64)
+	if !ok {
+		c.reply("CLIENT_ERROR invalid numeric delta argument")
+		return nil
+	}
+
+	var reply string
+	var key = string(args[0])
+	var item []byte
+	_, stored := c.cache().Compute(key, func(old []byte, exist bool) ([]byte, time.Duration, memorycache.ComputeOp) {
+		if !exist {
+			reply = "NOT_FOUND"
+			return old, 0, memorycache.ComputeKeep
+		}
+		n, ok := parseUint(old, 64)
+		if !ok {
+			reply = "CLIENT_ERROR cannot increment or decrement non-numeric value"
+			return old, 0, memorycache.ComputeKeep
+		}
+		switch {
+		case name == "incr":
+			n += delta
+		case delta > n:
+			n = 0
+		default:
+			n -= delta
+		}
+
+		// 保留原有的标志位和过期时间
+		var m, _ = c.server.meta.get(key, old)
+		reply = strconv.FormatUint(n, 10)
+		item = append(newValue(len(reply)), reply...)
+		return c.server.stamp(key, item, m.flags), 0, memorycache.ComputeUpdate
+	})
+	if item != nil && !stored {
+		c.server.meta.remove(key, item)
+	}
+	c.reply(reply)
+	return nil
+}
+
+// touch <key> <exptime> [noreply]
+func cmdTouch(c *client, name string, args [][]byte) error {
+	if len(args) != 2 || !validKey(args[0]) {
+		c.errFormat()
+		return nil
+	}
+	exptime, ok := parseInt(args[1])
+	if !ok {
+		c.reply("CLIENT_ERROR invalid exptime argument")
+		return nil
+	}
+	c.server.cmdTouch.Add(1)
+
+	var d, expired = toTTL(exptime, time.Now())
+	if _, _, exist := c.touch(string(args[0]), d, expired); exist {
+		c.reply("TOUCHED")
+	} else {
+		c.reply("NOT_FOUND")
+	}
+	return nil
+}
+
+// flush_all [delay] [noreply]
+func cmdFlushAll(c *client, name string, args [][]byte) error {
+	var delay int64
+	if len(args) > 1 {
+		c.errFormat()
+		return nil
+	}
+	if len(args) == 1 {
+		var ok bool
+		if delay, ok = parseInt(args[0]); !ok || delay < 0 {
+			c.errFormat()
+			return nil
+		}
+	}
+
+	c.server.cmdFlush.Add(1)
+	if delay == 0 {
+		c.cache().Clear()
+	} else {
+		c.server.flushAfter(time.Duration(delay) * time.Second)
+	}
+	c.reply("OK")
+	return nil
+}
+
+// stats [reset]. 命中, 写入和驱逐数据来自缓存的统计, 元素数量来自 Len.
+func cmdStats(c *client, name string, args [][]byte) error {
+	var s = c.server
+	if len(args) == 1 && string(args[0]) == "reset" {
+		s.cmdGet.Store(0)
+		s.cmdSet.Store(0)
+		s.cmdTouch.Store(0)
+		s.cmdFlush.Store(0)
+		c.cache().ResetStats()
+		c.reply("RESET")
+		return nil
+	}
+	if len(args) > 0 {
+		c.reply("ERROR")
+		return nil
+	}
+
+	var now = time.Now()
+	var stats = c.cache().Stats()
+	var b strings.Builder
+	var stat = func(name string, value any) {
+		b.WriteString("STAT ")
+		b.WriteString(name)
+		b.WriteString(" ")
+		switch v := value.(type) {
+		case string:
+			b.WriteString(v)
+		case int64:
+			b.WriteString(strconv.FormatInt(v, 10))
+		case uint64:
+			b.WriteString(strconv.FormatUint(v, 10))
+		}
+		b.WriteString("\r\n")
+	}
+	stat("pid", int64(os.Getpid()))
+	stat("uptime", int64(now.Sub(s.started).Seconds()))
+	stat("time", now.Unix())
+	stat("version", version)
+	stat("pointer_size", int64(strconv.IntSize))
+	stat("curr_connections", int64(s.base.Clients()))
+	stat("total_connections", s.base.Connections())
+	stat("cmd_get", s.cmdGet.Load())
+	stat("cmd_set", s.cmdSet.Load())
+	stat("cmd_flush", s.cmdFlush.Load())
+	stat("cmd_touch", s.cmdTouch.Load())
+	stat("get_hits", stats.Hits)
+	stat("get_misses", stats.Misses)
+	stat("delete_hits", stats.Deletes)
+	stat("curr_items", int64(c.cache().Len()))
+	stat("total_items", stats.Sets+stats.Updates)
+	stat("evictions", stats.Evictions(memorycache.ReasonEvicted))
+	stat("expired", stats.Evictions(memorycache.ReasonExpired))
+	b.WriteString("END")
+	c.reply(b.String())
+	return nil
+}
+
+func cmdVersion(c *client, name string, args [][]byte) error {
+	c.reply("VERSION " + version)
+	return nil
+}
+
+func cmdVerbosity(c *client, name string, args [][]byte) error {
+	c.reply("OK")
+	return nil
+}
+
+func cmdQuit(c *client, name string, args [][]byte) error {
+	c.quit = true
+	return nil
+}
@@ -0,0 +1,86 @@
+package memcached
+
+import (
+	"sync"
+
+	"github.com/lxzan/memorycache"
+)
+
+// 值的元数据: 客户端标志位和CAS唯一值. 缓存中只保存数据本身, 元数据保存在服务器的附表中.
+type meta struct {
+	flags  uint32
+	unique uint64
+
+	// 元数据所属的值. 值被其他客户端改写后底层数组不同, 元数据随之失效;
+	// 持有引用保证底层数组不会被回收后分配给其他值.
+	value []byte
+}
+
+// 是否是同一个值. 服务器写入的值容量至少为1, 通过底层数组的地址识别.
+func sameValue(a, b []byte) bool {
+	return cap(a) > 0 && cap(b) > 0 && len(a) == len(b) && &a[:cap(a)][0] == &b[:cap(b)][0]
+}
+
+// 创建长度为0, 容量为size的值. 容量至少为1, 以便 sameValue 识别空值.
+func newValue(size int) []byte {
+	return make([]byte, 0, size+1)
+}
+
+// 元数据附表. 写入元数据的操作都在键所在存储桶的锁内执行, 与值的写入是原子的;
+// 值被其他途径改写, 删除, 过期, 驱逐或清空时, 订阅的过滤函数在同一个锁内删除过时的元数据.
+type metaTable struct {
+	mu     sync.Mutex
+	items  map[string]meta
+	cancel func()
+}
+
+func newMetaTable(cache *memorycache.MemoryCache[string, []byte]) *metaTable {
+	var c = &metaTable{items: make(map[string]meta)}
+	_, c.cancel = cache.Subscribe(0, c.filter)
+	return c
+}
+
+// 维护附表的过滤函数, 不投递任何事件
+func (c *metaTable) filter(event memorycache.Event[string, []byte]) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if event.Type == memorycache.EventClear {
+		c.items = make(map[string]meta)
+		return false
+	}
+	if m, ok := c.items[event.Key]; ok && !sameValue(m.value, event.NewValue) {
+		delete(c.items, event.Key)
+	}
+	return false
+}
+
+// 查询值的元数据. 值不是由服务器写入的或者还没有分配CAS唯一值时返回false.
+func (c *metaTable) get(key string, value []byte) (meta, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, ok := c.items[key]
+	if !ok || !sameValue(m.value, value) {
+		return meta{}, false
+	}
+	return m, true
+}
+
+// 记录值的元数据, 调用方持有键所在存储桶的锁
+func (c *metaTable) put(key string, m meta) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[key] = m
+}
+
+// 删除属于value的元数据, 用于没有被写入缓存的值
+func (c *metaTable) remove(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if m, ok := c.items[key]; ok && sameValue(m.value, value) {
+		delete(c.items, key)
+	}
+}
+
+func (c *metaTable) close() {
+	c.cancel()
+}
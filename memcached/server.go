@@ -0,0 +1,188 @@
+// Package memcached 通过memcached文本协议提供 MemoryCache[string, []byte] 服务.
+// Package memcached serves a MemoryCache[string, []byte] over the memcached text protocol.
+package memcached
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lxzan/memorycache"
+	"github.com/lxzan/memorycache/internal/netserver"
+)
+
+// ErrServerClosed Serve 在 Close 之后返回的错误
+// Error returned by Serve after Close
+var ErrServerClosed = errors.New("memcached: server closed")
+
+const (
+	// 单个值的最大长度
+	maxItemSize = 1 << 20
+
+	// 命令行的最大长度, 超出后关闭连接
+	maxLineSize = 64 * 1024
+
+	// 大于30天的过期时间被视为Unix时间戳
+	maxRelativeExptime = 30 * 24 * 3600
+)
+
+// Server memcached文本协议服务器. 所有写命令通过 Compute 执行, 因此 add/replace/cas/incr 等命令是原子的.
+// 缓存中只保存数据, 客户端标志位和CAS唯一值保存在服务器的附表中, 因此缓存可以与其他协议共享.
+// 其他途径写入的值标志位为0, 第一次被 gets 读取时分配CAS唯一值. 标志位和CAS唯一值不会被持久化.
+// stats 命令的命中数据来自缓存的统计, 需要开启 WithStats(true).
+// Memcached text protocol server. All write commands run through Compute,
+// so commands such as add/replace/cas/incr are atomic.
+// The cache holds only the data; client flags and CAS uniques are kept in a side table of the server,
+// so the cache can be shared with other protocols. Values written by other means have flags 0 and are given
+// a CAS unique the first time gets reads them. Flags and CAS uniques are not persisted.
+// Hit counters of the stats command come from the cache statistics, which require WithStats(true).
+type Server struct {
+	base  *netserver.Server
+	cache *memorycache.MemoryCache[string, []byte]
+
+	// 最近分配的CAS唯一值, 每次写入递增
+	unique atomic.Uint64
+
+	// 标志位和CAS唯一值的附表
+	meta *metaTable
+
+	// 延迟的 flush_all
+	mu     sync.Mutex
+	closed bool
+	timers map[*time.Timer]struct{}
+
+	started  time.Time
+	cmdGet   atomic.Uint64
+	cmdSet   atomic.Uint64
+	cmdTouch atomic.Uint64
+	cmdFlush atomic.Uint64
+}
+
+// New 创建服务器
+// Create a server
+func New(cache *memorycache.MemoryCache[string, []byte]) *Server {
+	var c = &Server{
+		cache:   cache,
+		meta:    newMetaTable(cache),
+		timers:  make(map[*time.Timer]struct{}),
+		started: time.Now(),
+	}
+	c.base = netserver.New(c.serveConn, ErrServerClosed)
+	return c
+}
+
+// ListenAndServe 监听TCP地址并提供服务
+// Listen on the TCP address and serve
+func (c *Server) ListenAndServe(addr string) error {
+	return c.base.ListenAndServe(addr)
+}
+
+// Serve 接受连接并提供服务, 直到 Close 被调用. 总是返回非nil的错误.
+// Accept connections and serve them until Close is called. Always returns a non-nil error.
+func (c *Server) Serve(ln net.Listener) error {
+	return c.base.Serve(ln)
+}
+
+// Close 关闭所有监听器和连接, 取消延迟的 flush_all, 并等待连接处理协程退出. 不会关闭缓存, 但之后不再维护附表.
+// Close all listeners and connections, cancel delayed flush_all commands and wait for the connection goroutines to exit.
+// The cache is not closed, but the side table is no longer maintained.
+func (c *Server) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	for timer := range c.timers {
+		timer.Stop()
+		delete(c.timers, timer)
+	}
+	c.mu.Unlock()
+	c.base.Close()
+	c.meta.close()
+	return nil
+}
+
+// 处理一个连接, 返回后连接被关闭
+func (c *Server) serveConn(conn net.Conn) {
+	var cli = &client{
+		server: c,
+		r:      bufio.NewReaderSize(conn, 16*1024),
+		w:      bufio.NewWriter(conn),
+	}
+	for !cli.quit {
+		line, err := cli.readLine()
+		if err != nil {
+			if errors.Is(err, errLineTooLong) {
+				cli.reply("CLIENT_ERROR line too long")
+				_ = cli.w.Flush()
+			}
+			return
+		}
+		if err := cli.execute(line); err != nil {
+			return
+		}
+
+		// 流水线中的命令处理完后再统一写出
+		if cli.r.Buffered() == 0 || cli.quit {
+			if err := cli.w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// 分配新的CAS唯一值
+func (c *Server) nextUnique() uint64 {
+	return c.unique.Add(1)
+}
+
+// 记录写入的值的标志位, 并分配新的CAS唯一值. 在键所在存储桶的锁内调用.
+func (c *Server) stamp(key string, value []byte, flags uint32) []byte {
+	c.meta.put(key, meta{flags: flags, unique: c.nextUnique(), value: value})
+	return value
+}
+
+// 查询值的元数据, 没有时分配新的CAS唯一值. 在键所在存储桶的锁内调用.
+func (c *Server) ensure(key string, value []byte) meta {
+	if m, ok := c.meta.get(key, value); ok {
+		return m
+	}
+	var m = meta{unique: c.nextUnique(), value: value}
+	c.meta.put(key, m)
+	return m
+}
+
+// 延迟清空缓存
+func (c *Server) flushAfter(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(d, func() {
+		c.mu.Lock()
+		_, ok := c.timers[timer]
+		delete(c.timers, timer)
+		c.mu.Unlock()
+		if ok {
+			c.cache.Clear()
+		}
+	})
+	c.timers[timer] = struct{}{}
+}
+
+// 将memcached的过期时间转换为存活时间. 0表示永不过期; 负数或已经过去的时间戳表示已过期.
+func toTTL(exptime int64, now time.Time) (d time.Duration, expired bool) {
+	switch {
+	case exptime == 0:
+		return 0, false
+	case exptime < 0:
+		return 0, true
+	case exptime > maxRelativeExptime:
+		d = time.Unix(exptime, 0).Sub(now)
+		return d, d <= 0
+	default:
+		return time.Duration(exptime) * time.Second, false
+	}
+}
@@ -0,0 +1,377 @@
+package memcached
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lxzan/memorycache"
+	"github.com/stretchr/testify/assert"
+)
+
+type testClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// 发送请求并读取一行回复
+func (c *testClient) Do(request string) string {
+	_, _ = c.conn.Write([]byte(request))
+	return c.ReadLine()
+}
+
+func (c *testClient) ReadLine() string {
+	_ = c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return err.Error()
+	}
+	return strings.TrimSuffix(line, "\r\n")
+}
+
+// 读取直到END的所有行
+func (c *testClient) ReadAll() []string {
+	var lines []string
+	for {
+		var line = c.ReadLine()
+		lines = append(lines, line)
+		if line == "END" || strings.Contains(line, "ERROR") || strings.Contains(line, "timeout") {
+			return lines
+		}
+	}
+}
+
+func (c *testClient) Get(command string) []string {
+	_, _ = c.conn.Write([]byte(command + "\r\n"))
+	return c.ReadAll()
+}
+
+func newTestServer(t *testing.T, options ...memorycache.Option) (*Server, *memorycache.MemoryCache[string, []byte], *testClient) {
+	var cache = memorycache.New[string, []byte](append([]memorycache.Option{memorycache.WithCachedTime(false)}, options...)...)
+	var srv = New(cache)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() {
+		_ = srv.Close()
+		cache.Stop()
+	})
+	return srv, cache, dial(t, ln.Addr().String())
+}
+
+func dial(t *testing.T, addr string) *testClient {
+	conn, err := net.Dial("tcp", addr)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return &testClient{conn: conn, r: bufio.NewReader(conn)}
+}
+
+func TestServer_Storage(t *testing.T) {
+	t.Run("get set", func(t *testing.T) {
+		_, cache, cli := newTestServer(t)
+		assert.Equal(t, cli.Get("get a"), []string{"END"})
+		assert.Equal(t, cli.Do("set a 5 0 5\r\nhello\r\n"), "STORED")
+		assert.Equal(t, cli.Get("get a b"), []string{"VALUE a 5 5", "hello", "END"})
+		assert.Equal(t, cli.Do("set b 0 0 0\r\n\r\n"), "STORED")
+		assert.Equal(t, cli.Get("get a b"), []string{"VALUE a 5 5", "hello", "VALUE b 0 0", "", "END"})
+
+		// 缓存中只保存数据, 标志位和CAS唯一值保存在附表中
+		v, _ := cache.Get("a")
+		assert.Equal(t, string(v), "hello")
+	})
+
+	t.Run("shared", func(t *testing.T) {
+		_, cache, cli := newTestServer(t)
+
+		// 其他途径写入的值原样返回, 标志位为0
+		cache.Set("a", []byte("0123456789abcdef"), time.Hour)
+		assert.Equal(t, cli.Get("get a"), []string{"VALUE a 0 16", "0123456789abcdef", "END"})
+		var lines = cli.Get("gets a")
+		assert.Equal(t, len(lines), 3)
+		var unique = strings.Fields(lines[0])[4]
+		assert.Equal(t, cli.Get("gets a")[0], lines[0])
+		assert.Equal(t, cli.Do("cas a 3 0 1 "+unique+"\r\nx\r\n"), "STORED")
+		assert.Equal(t, cli.Get("get a"), []string{"VALUE a 3 1", "x", "END"})
+
+		// 其他途径改写之后, 原有的标志位和CAS唯一值失效
+		lines = cli.Get("gets a")
+		unique = strings.Fields(lines[0])[4]
+		cache.Set("a", []byte("y"), time.Hour)
+		assert.Equal(t, cli.Do("cas a 0 0 1 "+unique+"\r\nz\r\n"), "EXISTS")
+		assert.Equal(t, cli.Get("get a"), []string{"VALUE a 0 1", "y", "END"})
+
+		cache.Set("n", []byte("41"), time.Hour)
+		assert.Equal(t, cli.Do("incr n 1\r\n"), "42")
+		v, _ := cache.Get("n")
+		assert.Equal(t, string(v), "42")
+
+		// 删除和清空之后不保留元数据
+		assert.Equal(t, cli.Do("set b 9 0 1\r\nb\r\n"), "STORED")
+		cache.Delete("b")
+		cache.Set("b", []byte("b"), time.Hour)
+		assert.Equal(t, cli.Get("get b"), []string{"VALUE b 0 1", "b", "END"})
+		assert.Equal(t, cli.Do("set c 9 0 1\r\nc\r\n"), "STORED")
+		cache.Clear()
+		assert.Equal(t, cli.Get("get c"), []string{"END"})
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		_, _, cli := newTestServer(t, memorycache.WithBucketNum(1), memorycache.WithMaxCost(100),
+			memorycache.WithWeigher(func(key string, value []byte) int64 { return int64(len(value)) }))
+		assert.Equal(t, cli.Do("set a 0 0 101\r\n"+strings.Repeat("x", 101)+"\r\n"), "SERVER_ERROR out of memory storing object")
+		assert.Equal(t, cli.Get("get a"), []string{"END"})
+		assert.Equal(t, cli.Do("set a 0 0 10\r\n"+strings.Repeat("x", 10)+"\r\n"), "STORED")
+	})
+
+	t.Run("add replace", func(t *testing.T) {
+		_, _, cli := newTestServer(t)
+		assert.Equal(t, cli.Do("replace a 0 0 1\r\n1\r\n"), "NOT_STORED")
+		assert.Equal(t, cli.Do("add a 0 0 1\r\n1\r\n"), "STORED")
+		assert.Equal(t, cli.Do("add a 0 0 1\r\n2\r\n"), "NOT_STORED")
+		assert.Equal(t, cli.Do("replace a 3 0 1\r\n3\r\n"), "STORED")
+		assert.Equal(t, cli.Get("get a"), []string{"VALUE a 3 1", "3", "END"})
+	})
+
+	t.Run("append prepend", func(t *testing.T) {
+		_, cache, cli := newTestServer(t)
+		assert.Equal(t, cli.Do("append a 0 0 1\r\nx\r\n"), "NOT_STORED")
+		assert.Equal(t, cli.Do("set a 7 100 2\r\nbc\r\n"), "STORED")
+		assert.Equal(t, cli.Do("append a 0 0 1\r\nd\r\n"), "STORED")
+		assert.Equal(t, cli.Do("prepend a 0 0 1\r\na\r\n"), "STORED")
+		assert.Equal(t, cli.Get("get a"), []string{"VALUE a 7 4", "abcd", "END"})
+		d := ttlOf(cache, "a")
+		assert.True(t, d > 99*time.Second && d <= 100*time.Second)
+	})
+
+	t.Run("cas", func(t *testing.T) {
+		_, _, cli := newTestServer(t)
+		assert.Equal(t, cli.Do("cas a 0 0 1 1\r\n1\r\n"), "NOT_FOUND")
+		assert.Equal(t, cli.Do("set a 1 0 1\r\n1\r\n"), "STORED")
+		var lines = cli.Get("gets a")
+		assert.Equal(t, len(lines), 3)
+		var fields = strings.Fields(lines[0])
+		assert.Equal(t, fields[:4], []string{"VALUE", "a", "1", "1"})
+		var unique = fields[4]
+
+		assert.Equal(t, cli.Do("cas a 2 0 1 "+unique+"\r\n2\r\n"), "STORED")
+		assert.Equal(t, cli.Do("cas a 2 0 1 "+unique+"\r\n3\r\n"), "EXISTS")
+		assert.Equal(t, cli.Get("get a"), []string{"VALUE a 2 1", "2", "END"})
+
+		// 写入相同的值也会更新CAS唯一值, 刷新过期时间不会
+		unique = strings.Fields(cli.Get("gets a")[0])[4]
+		assert.Equal(t, cli.Do("touch a 100\r\n"), "TOUCHED")
+		assert.Equal(t, strings.Fields(cli.Get("gets a")[0])[4], unique)
+		assert.Equal(t, cli.Do("set a 2 0 1\r\n2\r\n"), "STORED")
+		assert.Equal(t, cli.Get("get a"), []string{"VALUE a 2 1", "2", "END"})
+		assert.Equal(t, cli.Do("cas a 0 0 1 "+unique+"\r\n5\r\n"), "EXISTS")
+		unique = strings.Fields(cli.Get("gets a")[0])[4]
+		assert.Equal(t, cli.Do("incr a 1\r\n"), "3")
+		assert.Equal(t, cli.Do("cas a 0 0 1 "+unique+"\r\n5\r\n"), "EXISTS")
+	})
+
+	t.Run("expire", func(t *testing.T) {
+		_, cache, cli := newTestServer(t)
+		assert.Equal(t, cli.Do("set a 0 100 1\r\n1\r\n"), "STORED")
+		d := ttlOf(cache, "a")
+		assert.True(t, d > 99*time.Second && d <= 100*time.Second)
+
+		var unix = time.Now().Add(time.Hour).Unix()
+		assert.Equal(t, cli.Do(fmt.Sprintf("set b 0 %d 1\r\n1\r\n", unix)), "STORED")
+		d = ttlOf(cache, "b")
+		assert.True(t, d > 59*time.Minute && d <= time.Hour)
+
+		assert.Equal(t, cli.Do("set a 0 -1 1\r\n1\r\n"), "STORED")
+		assert.Equal(t, cli.Get("get a"), []string{"END"})
+		assert.Equal(t, cli.Do(fmt.Sprintf("set b 0 %d 1\r\n1\r\n", time.Now().Add(-time.Hour).Unix())), "STORED")
+		assert.Equal(t, cli.Get("get b"), []string{"END"})
+	})
+
+	t.Run("noreply", func(t *testing.T) {
+		_, _, cli := newTestServer(t)
+		_, _ = cli.conn.Write([]byte("set a 0 0 1 noreply\r\n1\r\nadd a 0 0 1 noreply\r\n2\r\nincr a 5 noreply\r\n"))
+		assert.Equal(t, cli.Get("get a"), []string{"VALUE a 0 1", "6", "END"})
+		_, _ = cli.conn.Write([]byte("delete a noreply\r\n"))
+		assert.Equal(t, cli.Get("get a"), []string{"END"})
+	})
+
+	t.Run("bad data", func(t *testing.T) {
+		_, _, cli := newTestServer(t)
+		assert.Equal(t, cli.Do("set a 0 0 1\r\n12\r\n"), "CLIENT_ERROR bad data chunk")
+		assert.Equal(t, cli.Do("set a 0 0\r\n"), "CLIENT_ERROR bad command line format")
+		assert.Equal(t, cli.Do("set a x 0 1\r\n"), "CLIENT_ERROR bad command line format")
+		assert.Equal(t, cli.Do("set "+strings.Repeat("k", 251)+" 0 0 1\r\n"), "CLIENT_ERROR bad command line format")
+
+		var size = maxItemSize + 1
+		assert.Equal(t, cli.Do(fmt.Sprintf("set a 0 0 %d\r\n%s\r\n", size, strings.Repeat("v", size))), "SERVER_ERROR object too large for cache")
+		assert.Equal(t, cli.Do("version\r\n"), "VERSION "+version)
+		assert.Equal(t, cli.Do("foo\r\n"), "ERROR")
+	})
+}
+
+func TestServer_Commands(t *testing.T) {
+	t.Run("delete", func(t *testing.T) {
+		_, _, cli := newTestServer(t)
+		assert.Equal(t, cli.Do("delete a\r\n"), "NOT_FOUND")
+		assert.Equal(t, cli.Do("set a 1 0 1\r\n1\r\n"), "STORED")
+		assert.Equal(t, cli.Do("delete a 10\r\n"), "CLIENT_ERROR bad command line format.  Usage: delete <key> [noreply]")
+		assert.Equal(t, cli.Do("delete a 0\r\n"), "DELETED")
+		assert.Equal(t, cli.Get("get a"), []string{"END"})
+
+		// 删除后重新写入, 标志位不会残留
+		assert.Equal(t, cli.Do("set a 0 0 1\r\n1\r\n"), "STORED")
+		assert.Equal(t, cli.Get("get a"), []string{"VALUE a 0 1", "1", "END"})
+	})
+
+	t.Run("incr decr", func(t *testing.T) {
+		_, _, cli := newTestServer(t)
+		assert.Equal(t, cli.Do("incr a 1\r\n"), "NOT_FOUND")
+		assert.Equal(t, cli.Do("set a 9 0 2\r\n10\r\n"), "STORED")
+		assert.Equal(t, cli.Do("incr a 5\r\n"), "15")
+		assert.Equal(t, cli.Do("decr a 20\r\n"), "0")
+		assert.Equal(t, cli.Do("set a 0 0 20\r\n18446744073709551615\r\n"), "STORED")
+		assert.Equal(t, cli.Do("incr a 2\r\n"), "1")
+		assert.Equal(t, cli.Do("incr a x\r\n"), "CLIENT_ERROR invalid numeric delta argument")
+		assert.Equal(t, cli.Do("set a 3 0 1\r\nx\r\n"), "STORED")
+		assert.Equal(t, cli.Do("incr a 1\r\n"), "CLIENT_ERROR cannot increment or decrement non-numeric value")
+		assert.Equal(t, cli.Do("set a 3 0 1\r\n1\r\n"), "STORED")
+		assert.Equal(t, cli.Do("incr a 1\r\n"), "2")
+		assert.Equal(t, cli.Get("get a"), []string{"VALUE a 3 1", "2", "END"})
+	})
+
+	t.Run("touch gat", func(t *testing.T) {
+		_, cache, cli := newTestServer(t)
+		assert.Equal(t, cli.Do("touch a 10\r\n"), "NOT_FOUND")
+		assert.Equal(t, cli.Do("set a 1 0 1\r\n1\r\n"), "STORED")
+		assert.Equal(t, cli.Do("touch a 10\r\n"), "TOUCHED")
+		d := ttlOf(cache, "a")
+		assert.True(t, d > 9*time.Second && d <= 10*time.Second)
+
+		assert.Equal(t, cli.Get("gat 0 a b"), []string{"VALUE a 1 1", "1", "END"})
+		d = ttlOf(cache, "a")
+		assert.Equal(t, d, time.Duration(0))
+		var lines = cli.Get("gats 100 a")
+		assert.Equal(t, strings.Fields(lines[0])[:4], []string{"VALUE", "a", "1", "1"})
+		d = ttlOf(cache, "a")
+		assert.True(t, d > 99*time.Second)
+
+		assert.Equal(t, cli.Get("gat -1 a"), []string{"END"})
+		assert.Equal(t, cache.Len(), 0)
+		assert.Equal(t, cli.Do("gat x a\r\n"), "CLIENT_ERROR invalid exptime argument")
+	})
+
+	t.Run("flush_all", func(t *testing.T) {
+		_, cache, cli := newTestServer(t)
+		assert.Equal(t, cli.Do("set a 1 0 1\r\n1\r\n"), "STORED")
+		assert.Equal(t, cli.Do("flush_all\r\n"), "OK")
+		assert.Equal(t, cache.Len(), 0)
+
+		assert.Equal(t, cli.Do("set a 1 0 1\r\n1\r\n"), "STORED")
+		assert.Equal(t, cli.Do("flush_all 1\r\n"), "OK")
+		assert.Equal(t, cache.Len(), 1)
+		time.Sleep(1200 * time.Millisecond)
+		assert.Equal(t, cache.Len(), 0)
+		assert.Equal(t, cli.Do("flush_all x\r\n"), "CLIENT_ERROR bad command line format")
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		_, _, cli := newTestServer(t, memorycache.WithStats(true))
+		assert.Equal(t, cli.Do("set a 1 0 1\r\n1\r\n"), "STORED")
+		cli.Get("get a b")
+		var stats = make(map[string]string)
+		for _, line := range cli.Get("stats") {
+			if fields := strings.Fields(line); len(fields) == 3 {
+				stats[fields[1]] = fields[2]
+			}
+		}
+		assert.Equal(t, stats["curr_items"], "1")
+		assert.Equal(t, stats["get_hits"], "1")
+		assert.Equal(t, stats["get_misses"], "1")
+		assert.Equal(t, stats["cmd_get"], "2")
+		assert.Equal(t, stats["cmd_set"], "1")
+		assert.Equal(t, stats["curr_connections"], "1")
+		assert.Equal(t, stats["version"], version)
+
+		assert.Equal(t, cli.Do("stats reset\r\n"), "RESET")
+		assert.Contains(t, cli.Get("stats"), "STAT cmd_get 0")
+		assert.Equal(t, cli.Do("stats slabs\r\n"), "ERROR")
+	})
+
+	t.Run("concurrent incr", func(t *testing.T) {
+		_, _, cli := newTestServer(t)
+		assert.Equal(t, cli.Do("set n 0 0 1\r\n0\r\n"), "STORED")
+		var addr = cli.conn.RemoteAddr().String()
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			var conn = dial(t, addr)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					conn.Do("incr n 1\r\n")
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, cli.Get("get n"), []string{"VALUE n 0 3", "400", "END"})
+	})
+
+	t.Run("quit", func(t *testing.T) {
+		_, _, cli := newTestServer(t)
+		_, _ = cli.conn.Write([]byte("quit\r\n"))
+		_, err := cli.r.ReadByte()
+		assert.Error(t, err)
+	})
+
+	t.Run("close", func(t *testing.T) {
+		var cache = memorycache.New[string, []byte]()
+		defer cache.Stop()
+		var srv = New(cache)
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		var done = make(chan error, 1)
+		go func() { done <- srv.Serve(ln) }()
+
+		var cli = dial(t, ln.Addr().String())
+		assert.Equal(t, cli.Do("version\r\n"), "VERSION "+version)
+		assert.Equal(t, cli.Do("flush_all 100\r\n"), "OK")
+		assert.NoError(t, srv.Close())
+		assert.ErrorIs(t, <-done, ErrServerClosed)
+		_, err = cli.r.ReadByte()
+		assert.Error(t, err)
+		assert.Equal(t, len(srv.timers), 0)
+	})
+}
+
+// 剩余存活时间, 永不过期时返回0
+func ttlOf(cache *memorycache.MemoryCache[string, []byte], key string) time.Duration {
+	if _, expireAt, ok := cache.Peek(key); ok && !expireAt.IsZero() {
+		return time.Until(expireAt)
+	}
+	return 0
+}
+
+func TestToTTL(t *testing.T) {
+	t.Run("", func(t *testing.T) {
+		var now = time.Now()
+		d, expired := toTTL(0, now)
+		assert.Equal(t, d, time.Duration(0))
+		assert.False(t, expired)
+
+		_, expired = toTTL(-1, now)
+		assert.True(t, expired)
+
+		d, _ = toTTL(maxRelativeExptime, now)
+		assert.Equal(t, d, maxRelativeExptime*time.Second)
+
+		d, expired = toTTL(now.Unix()+60, time.Unix(now.Unix(), 0))
+		assert.Equal(t, d, time.Minute)
+		assert.False(t, expired)
+
+		_, expired = toTTL(now.Unix()-60, now)
+		assert.True(t, expired)
+	})
+}
@@ -0,0 +1,175 @@
+// Package metrics 以Prometheus文本格式导出 MemoryCache 的统计数据, 不依赖Prometheus客户端库.
+// Package metrics exports MemoryCache statistics in the Prometheus text exposition format,
+// without depending on the Prometheus client library.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lxzan/memorycache"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// 导出的删除原因
+var reasons = []memorycache.Reason{
+	memorycache.ReasonExpired,
+	memorycache.ReasonEvicted,
+	memorycache.ReasonDeleted,
+	memorycache.ReasonCleared,
+	memorycache.ReasonClosed,
+}
+
+// Source 统计数据来源, *memorycache.MemoryCache[K, V] 实现了该接口
+// Statistics source, implemented by *memorycache.MemoryCache[K, V]
+type Source interface {
+	Len() int
+	Cost() int64
+	Stats() memorycache.Stats
+	Buckets() []memorycache.BucketInfo
+}
+
+// Handler 导出一个或多个命名缓存实例的统计数据
+// Exports statistics for one or more named cache instances
+type Handler struct {
+	mu      sync.RWMutex
+	sources map[string]Source
+}
+
+// NewHandler 创建导出器
+// Create an exporter
+func NewHandler() *Handler {
+	return &Handler{sources: make(map[string]Source)}
+}
+
+// Register 注册缓存实例, 相同名称的实例会被替换
+// Register a cache instance, an instance with the same name is replaced
+func (c *Handler) Register(name string, source Source) *Handler {
+	c.mu.Lock()
+	c.sources[name] = source
+	c.mu.Unlock()
+	return c
+}
+
+// Unregister 注销缓存实例
+// Unregister a cache instance
+func (c *Handler) Unregister(name string) {
+	c.mu.Lock()
+	delete(c.sources, name)
+	c.mu.Unlock()
+}
+
+func (c *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	_ = c.Write(w)
+}
+
+type sample struct {
+	name  string
+	stats memorycache.Stats
+	src   Source
+}
+
+// Write 以Prometheus文本格式写入所有实例的统计数据
+// Write the statistics of all instances in the Prometheus text format
+func (c *Handler) Write(w io.Writer) error {
+	c.mu.RLock()
+	var samples = make([]sample, 0, len(c.sources))
+	for name, src := range c.sources {
+		samples = append(samples, sample{name: name, src: src})
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i].name < samples[j].name })
+	for i := range samples {
+		samples[i].stats = samples[i].src.Stats()
+	}
+
+	var bw = bufio.NewWriter(w)
+	var gauge = func(name, help string, f func(s *sample) string) {
+		writeFamily(bw, name, "gauge", help, samples, f)
+	}
+	var counter = func(name, help string, f func(s *sample) string) {
+		writeFamily(bw, name, "counter", help, samples, f)
+	}
+
+	gauge("memorycache_entries", "Number of cached entries.", func(s *sample) string {
+		return line("memorycache_entries", s.name, "", strconv.Itoa(s.src.Len()))
+	})
+	gauge("memorycache_cost", "Total cost of cached entries.", func(s *sample) string {
+		return line("memorycache_cost", s.name, "", strconv.FormatInt(s.src.Cost(), 10))
+	})
+	gauge("memorycache_bucket_entries", "Number of cached entries per bucket.", func(s *sample) string {
+		var b strings.Builder
+		for i, item := range s.src.Buckets() {
+			b.WriteString(line("memorycache_bucket_entries", s.name, `bucket="`+strconv.Itoa(i)+`"`, strconv.Itoa(item.Len)))
+		}
+		return b.String()
+	})
+	counter("memorycache_hits_total", "Number of cache hits.", func(s *sample) string {
+		return line("memorycache_hits_total", s.name, "", formatUint(s.stats.Hits))
+	})
+	counter("memorycache_misses_total", "Number of cache misses.", func(s *sample) string {
+		return line("memorycache_misses_total", s.name, "", formatUint(s.stats.Misses))
+	})
+	counter("memorycache_sets_total", "Number of inserted entries.", func(s *sample) string {
+		return line("memorycache_sets_total", s.name, "", formatUint(s.stats.Sets))
+	})
+	counter("memorycache_updates_total", "Number of updated entries.", func(s *sample) string {
+		return line("memorycache_updates_total", s.name, "", formatUint(s.stats.Updates))
+	})
+	counter("memorycache_rejects_total", "Number of rejected writes.", func(s *sample) string {
+		return line("memorycache_rejects_total", s.name, "", formatUint(s.stats.Rejects))
+	})
+	counter("memorycache_evictions_total", "Number of removed entries by reason.", func(s *sample) string {
+		var b strings.Builder
+		for _, reason := range reasons {
+			b.WriteString(line("memorycache_evictions_total", s.name, `reason="`+reason.String()+`"`, formatUint(s.stats.Evictions(reason))))
+		}
+		return b.String()
+	})
+	counter("memorycache_janitor_runs_total", "Number of background expiration checks.", func(s *sample) string {
+		return line("memorycache_janitor_runs_total", s.name, "", formatUint(s.stats.JanitorRuns))
+	})
+	counter("memorycache_janitor_duration_seconds_total", "Cumulative duration of background expiration checks.", func(s *sample) string {
+		return line("memorycache_janitor_duration_seconds_total", s.name, "", strconv.FormatFloat(s.stats.JanitorDuration.Seconds(), 'g', -1, 64))
+	})
+
+	return bw.Flush()
+}
+
+func writeFamily(w *bufio.Writer, name, kind, help string, samples []sample, f func(s *sample) string) {
+	if len(samples) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	for i := range samples {
+		_, _ = w.WriteString(f(&samples[i]))
+	}
+}
+
+func line(name, cache, labels, value string) string {
+	var s = name + `{cache="` + escape(cache) + `"`
+	if labels != "" {
+		s += "," + labels
+	}
+	return s + "} " + value + "\n"
+}
+
+func formatUint(v uint64) string {
+	return strconv.FormatUint(v, 10)
+}
+
+var replacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// 转义标签值
+func escape(s string) string {
+	return replacer.Replace(s)
+}
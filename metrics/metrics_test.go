@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lxzan/memorycache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		var rec = httptest.NewRecorder()
+		NewHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		assert.Equal(t, rec.Header().Get("Content-Type"), contentType)
+		assert.Equal(t, rec.Body.Len(), 0)
+	})
+
+	t.Run("render", func(t *testing.T) {
+		var mc = memorycache.New[string, int](
+			memorycache.WithStats(true),
+			memorycache.WithBucketNum(2),
+		)
+		mc.Set("a", 1, time.Hour)
+		mc.Set("b", 1, time.Hour)
+		mc.Get("a")
+		mc.Get("c")
+		mc.Delete("b")
+
+		var h = NewHandler().Register("users", mc).Register(`x"y`, memorycache.New[int, int]())
+		var rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		body, _ := io.ReadAll(rec.Body)
+		var text = string(body)
+
+		assert.Contains(t, text, "# TYPE memorycache_entries gauge\n")
+		assert.Contains(t, text, "# TYPE memorycache_hits_total counter\n")
+		assert.Contains(t, text, `memorycache_entries{cache="users"} 1`+"\n")
+		assert.Contains(t, text, `memorycache_bucket_entries{cache="users",bucket="0"}`)
+		assert.Contains(t, text, `memorycache_bucket_entries{cache="users",bucket="1"}`)
+		assert.Contains(t, text, `memorycache_hits_total{cache="users"} 1`+"\n")
+		assert.Contains(t, text, `memorycache_misses_total{cache="users"} 1`+"\n")
+		assert.Contains(t, text, `memorycache_sets_total{cache="users"} 2`+"\n")
+		assert.Contains(t, text, `memorycache_evictions_total{cache="users",reason="deleted"} 1`+"\n")
+		assert.Contains(t, text, `memorycache_evictions_total{cache="users",reason="expired"} 0`+"\n")
+		assert.Contains(t, text, `memorycache_janitor_runs_total{cache="users"} 0`+"\n")
+		assert.Contains(t, text, `memorycache_entries{cache="x\"y"} 0`+"\n")
+		assert.Equal(t, strings.Count(text, "# TYPE memorycache_entries "), 1)
+		assert.Less(t, strings.Index(text, `cache="users"`), strings.Index(text, `cache="x\"y"`))
+
+		h.Unregister(`x"y`)
+		var sb strings.Builder
+		assert.NoError(t, h.Write(&sb))
+		assert.NotContains(t, sb.String(), `x\"y`)
+	})
+}
+
+func TestEscape(t *testing.T) {
+	assert.Equal(t, escape(`a\b"c`+"\n"), `a\\b\"c\n`)
+}
@@ -1,6 +1,8 @@
 package memorycache
 
 import (
+	"fmt"
+	"reflect"
 	"time"
 
 	"github.com/lxzan/memorycache/internal/utils"
@@ -13,6 +15,9 @@ const (
 	defaultDeleteLimits = 1000
 	defaultBucketSize   = 1000
 	defaultBucketCap    = 100000
+	defaultRewriteSize  = 64 << 20
+
+	defaultRefreshRetryInterval = time.Second
 )
 
 type Option func(c *config)
@@ -69,6 +74,123 @@ func WithSwissTable(enabled bool) Option {
 	}
 }
 
+// WithEvictionPolicy 设置容量溢出时的淘汰策略, 默认为LRU
+// Set the eviction policy used when the capacity overflows, LRU by default.
+func WithEvictionPolicy(policy EvictionPolicy) Option {
+	return func(c *config) {
+		c.EvictionPolicy = policy
+	}
+}
+
+// WithAdmission 设置准入策略, 默认总是允许写入.
+// AdmissionTinyLFU 为每个存储桶维护一个带门卫布隆过滤器的Count-Min Sketch, 存储桶已满时,
+// 只有新键的估计访问频率高于淘汰候选者才会被写入, 避免只访问一次的键挤掉热点数据. 被拒绝的写入以 ReasonEvicted 触发回调.
+// Set the admission policy, always admit by default.
+// AdmissionTinyLFU keeps a Count-Min Sketch with a doorkeeper bloom filter per bucket. When a bucket is full,
+// a new key is only written if its estimated access frequency is higher than that of the eviction candidate,
+// so one-hit wonders do not push out hot entries. Rejected writes trigger the callback with ReasonEvicted.
+func WithAdmission(admission Admission) Option {
+	return func(c *config) {
+		c.Admission = admission
+	}
+}
+
+// WithMaxCost 设置最大总开销, 平均分配到每个存储桶. 写入时淘汰元素直到满足存储桶的开销上限.
+// 因此单个元素的开销不能超过 total/BucketNum: 超过的新元素不会被写入, 更新后超过的元素会被淘汰.
+// 需要缓存较大的值时, 使用 WithGlobalCapacity 设置所有存储桶共享的开销上限, 或者减少存储桶数量. 默认不限制.
+// Set the maximum total cost, divided evenly among buckets. Writes evict elements until the bucket's cost limit is satisfied.
+// As a result, the cost of a single element cannot exceed total/BucketNum: new elements above it are not written,
+// and elements updated above it are evicted. To cache large values, use WithGlobalCapacity to set a cost limit shared
+// by all buckets, or use fewer buckets. Unlimited by default.
+func WithMaxCost(total int64) Option {
+	return func(c *config) {
+		c.MaxCost = total
+	}
+}
+
+// WithGlobalCapacity 设置整个缓存的最大元素数量和最大总开销, <=0表示不限制. 默认不限制.
+// 与 WithBucketSize 和 WithMaxCost 的单个存储桶限制不同, 全局容量使用所有存储桶共享的计数, 不受哈希分布不均的影响.
+// 超出全局容量时, 在正在写入的存储桶和抽样的存储桶中选择元素最多的一个淘汰.
+// Set the maximum number of entries and the maximum total cost of the whole cache, <=0 means unlimited. Unlimited by default.
+// Unlike the per-bucket limits of WithBucketSize and WithMaxCost, the global capacity uses counters shared by all buckets
+// and is not affected by skewed hashing. When it is exceeded, an element is evicted from the bucket being written
+// or a sampled bucket, whichever has more elements.
+func WithGlobalCapacity(entries int, cost int64) Option {
+	return func(c *config) {
+		c.GlobalEntries = entries
+		c.GlobalCost = cost
+	}
+}
+
+// WithWeigher 设置开销计算函数, 默认每个元素的开销为1. K, V 与 New 的类型参数不一致时 New 会panic.
+// Set the cost calculation function, the cost of each element is 1 by default.
+// New panics if K and V differ from its type parameters.
+func WithWeigher[K comparable, V any](weigher WeigherFunc[K, V]) Option {
+	return func(c *config) {
+		c.Weigher = weigher
+	}
+}
+
+// WithStats 是否开启统计. 统计数据按存储桶记录, 不会引入新的锁竞争.
+// Whether to enable statistics. Statistics are recorded per bucket and do not introduce new lock contention.
+func WithStats(enabled bool) Option {
+	return func(c *config) {
+		c.Stats = enabled
+	}
+}
+
+// WithLoaderErrorTTL 设置加载失败结果的缓存时长, 默认不缓存.
+// 在此期间, GetOrLoad 直接返回该错误, 不会再次调用加载函数.
+// Set how long a failed load result is cached, not cached by default.
+// During this time GetOrLoad returns the error directly without calling the loader again.
+func WithLoaderErrorTTL(d time.Duration) Option {
+	return func(c *config) {
+		c.LoaderErrorTTL = d
+	}
+}
+
+// WithLoader 注册数据加载函数, 用于在后台重新加载陈旧数据. K, V 与 New 的类型参数不一致时 New 会panic.
+// Register a data loading function used to reload stale values in the background.
+// New panics if K and V differ from its type parameters.
+func WithLoader[K comparable, V any](loader LoaderFunc[K, V]) Option {
+	return func(c *config) {
+		c.Loader = loader
+	}
+}
+
+// WithRefreshAhead 设置提前刷新. 当剩余存活时间不足 fraction*TTL 时, Get 立即返回当前值并在后台调用loader重新加载.
+// 后台检查协程也会主动刷新即将过期的元素. fraction 取值范围 (0, 1]. K, V 与 New 的类型参数不一致时 New 会panic.
+// Set refresh-ahead. When the remaining time to live is less than fraction*TTL, Get returns the current value immediately
+// and calls loader in the background to reload it. The background check goroutine also refreshes expiring elements proactively.
+// fraction is in the range (0, 1]. New panics if K and V differ from its type parameters.
+func WithRefreshAhead[K comparable, V any](loader LoaderFunc[K, V], fraction float64) Option {
+	return func(c *config) {
+		c.Loader = loader
+		c.RefreshFraction = fraction
+	}
+}
+
+// WithRefreshRetryInterval 设置提前刷新失败之后再次尝试的最小间隔, 默认为1秒.
+// Set the minimum interval before a failed refresh-ahead is retried, 1 second by default.
+func WithRefreshRetryInterval(d time.Duration) Option {
+	return func(c *config) {
+		c.RefreshRetryInterval = d
+	}
+}
+
+// 读取类型为T的选项, 未设置时返回false. 选项的类型与缓存的类型参数不一致属于使用错误, 直接panic, 避免被静默忽略.
+func optionOf[T any](name string, value any) (T, bool) {
+	if value == nil {
+		var zero T
+		return zero, false
+	}
+	result, ok := value.(T)
+	if !ok {
+		panic(fmt.Sprintf("memorycache: %s has type %T, want %s", name, value, reflect.TypeOf((*T)(nil)).Elem()))
+	}
+	return result, true
+}
+
 func withInitialize() Option {
 	return func(c *config) {
 		if c.BucketNum <= 0 {
@@ -95,6 +217,86 @@ func withInitialize() Option {
 		if c.BucketCap <= 0 {
 			c.BucketCap = defaultBucketCap
 		}
+
+		if c.RefreshFraction > 1 {
+			c.RefreshFraction = 1
+		}
+
+		if c.RefreshRetryInterval <= 0 {
+			c.RefreshRetryInterval = defaultRefreshRetryInterval
+		}
+
+		if c.AOFRewriteSize <= 0 {
+			c.AOFRewriteSize = defaultRewriteSize
+		}
+
+		if c.SnapshotRetain <= 0 {
+			c.SnapshotRetain = 1
+		}
+
+		if c.CallbackQueueSize <= 0 {
+			c.CallbackQueueSize = defaultCallbackQueueSize
+		}
+	}
+}
+
+// WithCodec 设置键值的编解码器, 用于追加日志和自动快照. 默认使用 JSONCodec. K, V 与 New 的类型参数不一致时 New 会panic.
+// Set the key-value codec used by the append-only log and automatic snapshots. JSONCodec is used by default.
+// New panics if K and V differ from its type parameters.
+func WithCodec[K comparable, V any](codec Codec[K, V]) Option {
+	return func(c *config) {
+		c.Codec = codec
+	}
+}
+
+// WithAOF 开启追加日志. 写入, 删除, 过期时间的刷新和过期都会记录到path, New 会重放日志以重建缓存.
+// 日志尾部不完整的记录会被截断; 中间的记录损坏时不修改文件, Err 返回 ErrAOFFormat. 持久化错误可以通过 Err 获取.
+// Enable the append-only log. Writes, deletions, TTL refreshes and expirations are recorded in path,
+// and New replays the log to rebuild the cache. An incomplete record at the end of the log is truncated;
+// if a record in the middle is corrupt, the file is left untouched and Err returns ErrAOFFormat.
+// Persistence errors are available through Err.
+func WithAOF(path string, policy FsyncPolicy) Option {
+	return func(c *config) {
+		c.AOFPath = path
+		c.AOFFsync = policy
+	}
+}
+
+// WithAOFRewriteSize 设置追加日志的重写阈值, 默认64MB. 日志大小超过阈值且超过上次重写后大小的两倍时, 在后台根据快照重写日志.
+// Set the rewrite threshold of the append-only log, 64MB by default. When the log is larger than the threshold
+// and twice its size after the last rewrite, it is rewritten from a snapshot in the background.
+func WithAOFRewriteSize(size int64) Option {
+	return func(c *config) {
+		c.AOFRewriteSize = size
+	}
+}
+
+// WithAutoSnapshot 开启自动快照. 后台检查协程每隔interval将快照写入dir, 保留最新的retain个, 默认保留1个.
+// New 会加载最新的有效快照; 同时开启追加日志时以追加日志为准, 不加载快照.
+// Enable automatic snapshots. The background check goroutine writes a snapshot to dir every interval
+// and keeps the newest retain ones, 1 by default. New loads the newest valid snapshot;
+// if the append-only log is also enabled, the log takes precedence and no snapshot is loaded.
+func WithAutoSnapshot(dir string, interval time.Duration, retain int) Option {
+	return func(c *config) {
+		c.SnapshotDir = dir
+		c.SnapshotInterval = interval
+		c.SnapshotRetain = retain
+	}
+}
+
+// WithAsyncCallback 异步调用删除回调. 通知(元素的副本和原因)在释放存储桶的锁之后进入容量为size的队列,
+// 由单独的协程按顺序在锁外投递, 回调中可以操作缓存. overflow 指定队列已满时的处理策略, 零值 OverflowDiscard 丢弃新的通知,
+// 被丢弃的通知数量见 Stats.DroppedCallbacks. Stop 会等待队列中的通知投递完成, 之后的回调恢复为同步调用.
+// Invoke removal callbacks asynchronously. Notifications (a copy of the element and the reason) are queued with capacity size
+// after the bucket lock is released, and delivered in order by a dedicated goroutine outside of any lock,
+// so callbacks may operate on the cache. overflow specifies what happens when the queue is full; the zero value
+// OverflowDiscard discards new notifications, and the number of discarded notifications is reported in Stats.DroppedCallbacks.
+// Stop waits for queued notifications to be delivered, after which callbacks are invoked synchronously again.
+func WithAsyncCallback(size int, overflow OverflowPolicy) Option {
+	return func(c *config) {
+		c.AsyncCallback = true
+		c.CallbackQueueSize = size
+		c.CallbackOverflow = overflow
 	}
 }
 
@@ -122,4 +324,70 @@ type config struct {
 	// 是否使用swiss table, 默认为false
 	// Whether to use swiss table, false by default.
 	SwissTable bool
+
+	// 淘汰策略, 默认为LRU
+	// Eviction policy, LRU by default.
+	EvictionPolicy EvictionPolicy
+
+	// 准入策略, 默认总是允许写入
+	// Admission policy, always admit by default.
+	Admission Admission
+
+	// 最大总开销, 默认为0, 不限制
+	// Maximum total cost, default is 0, unlimited.
+	MaxCost int64
+
+	// 整个缓存的最大元素数量和最大总开销, 默认为0, 不限制
+	// Maximum number of entries and maximum total cost of the whole cache, default is 0, unlimited.
+	GlobalEntries int
+	GlobalCost    int64
+
+	// 开销计算函数, 类型为 WeigherFunc[K, V]
+	// Cost calculation function, of type WeigherFunc[K, V].
+	Weigher any
+
+	// 是否开启统计, 默认为false
+	// Whether to enable statistics, false by default.
+	Stats bool
+
+	// 加载失败结果的缓存时长, 默认为0, 不缓存
+	// How long a failed load result is cached, default is 0, not cached.
+	LoaderErrorTTL time.Duration
+
+	// 数据加载函数, 类型为 LoaderFunc[K, V]
+	// Data loading function, of type LoaderFunc[K, V].
+	Loader any
+
+	// 提前刷新的时间窗口占TTL的比例, 默认为0, 不刷新
+	// Fraction of the TTL used as the refresh-ahead window, default is 0, no refresh.
+	RefreshFraction float64
+
+	// 提前刷新失败之后再次尝试的最小间隔, 默认为1秒
+	// Minimum interval before a failed refresh-ahead is retried, 1 second by default.
+	RefreshRetryInterval time.Duration
+
+	// 键值的编解码器, 类型为 Codec[K, V]
+	// Key-value codec, of type Codec[K, V].
+	Codec any
+
+	// 追加日志的路径和刷盘策略, 路径为空时不开启
+	// Path and fsync policy of the append-only log, disabled if the path is empty.
+	AOFPath  string
+	AOFFsync FsyncPolicy
+
+	// 追加日志的重写阈值, 默认为64MB
+	// Rewrite threshold of the append-only log, default is 64MB.
+	AOFRewriteSize int64
+
+	// 自动快照的目录, 周期和保留数量, 目录为空或周期<=0时不开启
+	// Directory, interval and retained number of automatic snapshots, disabled if the directory is empty or the interval is <=0.
+	SnapshotDir      string
+	SnapshotInterval time.Duration
+	SnapshotRetain   int
+
+	// 是否异步调用回调, 以及队列容量和溢出策略. 队列容量默认为1024.
+	// Whether callbacks are invoked asynchronously, the queue capacity and the overflow policy. The capacity defaults to 1024.
+	AsyncCallback     bool
+	CallbackQueueSize int
+	CallbackOverflow  OverflowPolicy
 }
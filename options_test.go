@@ -1,6 +1,9 @@
 package memorycache
 
 import (
+	"context"
+	"math"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -90,3 +93,136 @@ func TestWithSwissTable(t *testing.T) {
 		assert.False(t, mc.conf.SwissTable)
 	})
 }
+
+func TestWithRefreshAhead(t *testing.T) {
+	var loader LoaderFunc[string, int] = func(ctx context.Context, key string) (int, time.Duration, error) {
+		return 0, 0, nil
+	}
+
+	t.Run("", func(t *testing.T) {
+		var mc = New[string, int](WithRefreshAhead(loader, 2))
+		assert.NotNil(t, mc.loader)
+		assert.Equal(t, mc.conf.RefreshFraction, 1.0)
+	})
+
+	t.Run("", func(t *testing.T) {
+		var mc = New[string, int](WithRefreshAhead(loader, 0))
+		assert.NotNil(t, mc.loader)
+		assert.Equal(t, mc.getRefreshAt(time.Second), int64(math.MaxInt64))
+	})
+
+	t.Run("", func(t *testing.T) {
+		assert.Panics(t, func() { New[string, any](WithRefreshAhead(loader, 0.5)) })
+	})
+}
+
+func TestWithLoader(t *testing.T) {
+	var mc = New[string, int](WithLoader(func(ctx context.Context, key string) (int, time.Duration, error) {
+		return 0, 0, nil
+	}))
+	assert.NotNil(t, mc.loader)
+	assert.Equal(t, mc.getRefreshAt(time.Second), int64(math.MaxInt64))
+
+	assert.Panics(t, func() {
+		New[string, string](WithLoader(func(ctx context.Context, key string) (int, time.Duration, error) {
+			return 0, 0, nil
+		}))
+	})
+}
+
+func TestWithCodec(t *testing.T) {
+	var mc = New[string, int](WithCodec[string, int](JSONCodec[string, int]{}))
+	assert.Equal(t, mc.codec(), Codec[string, int](JSONCodec[string, int]{}))
+	assert.Equal(t, New[string, int]().codec(), Codec[string, int](JSONCodec[string, int]{}))
+
+	assert.Panics(t, func() { New[string, string](WithCodec[string, int](JSONCodec[string, int]{})) })
+}
+
+func TestWithEvictionPolicy(t *testing.T) {
+	{
+		var mc = New[string, int]()
+		_, ok := mc.storage[0].Policy.(*lruPolicy[string, int])
+		assert.True(t, ok)
+	}
+	{
+		var mc = New[string, int](WithEvictionPolicy(EvictionSIEVE))
+		_, ok := mc.storage[0].Policy.(*sievePolicy[string, int])
+		assert.True(t, ok)
+	}
+}
+
+func TestWithAdmission(t *testing.T) {
+	{
+		var mc = New[string, int]()
+		assert.Nil(t, mc.storage[0].Sketch)
+	}
+	{
+		var mc = New[string, int](WithAdmission(AdmissionTinyLFU))
+		assert.NotNil(t, mc.storage[0].Sketch)
+	}
+}
+
+func TestWithMaxCost(t *testing.T) {
+	{
+		var mc = New[string, int]()
+		assert.Equal(t, mc.storage[0].budget, int64(0))
+	}
+	{
+		var mc = New[string, int](WithBucketNum(4), WithMaxCost(100))
+		assert.Equal(t, mc.storage[0].budget, int64(25))
+		assert.Nil(t, mc.weigher)
+	}
+	{
+		var mc = New[string, int](WithBucketNum(4), WithMaxCost(2), WithWeigher(func(key string, value int) int64 {
+			return int64(value)
+		}))
+		assert.Equal(t, mc.storage[0].budget, int64(1))
+		assert.NotNil(t, mc.weigher)
+	}
+	{
+		assert.Panics(t, func() {
+			New[int, int](WithWeigher(func(key string, value int) int64 { return 1 }))
+		})
+	}
+}
+
+func TestWithGlobalCapacity(t *testing.T) {
+	{
+		var mc = New[string, int]()
+		assert.Nil(t, mc.storage[0].global)
+	}
+	{
+		var mc = New[string, int](WithGlobalCapacity(100, 1000))
+		var global = mc.storage[0].global
+		assert.Equal(t, global.maxEntries, int64(100))
+		assert.Equal(t, global.maxCost, int64(1000))
+		assert.Equal(t, len(global.storage), mc.conf.BucketNum)
+		assert.True(t, global == mc.storage[1].global)
+	}
+}
+
+func TestWithAOF(t *testing.T) {
+	{
+		var mc = New[string, int]()
+		assert.Nil(t, mc.aof)
+		assert.Equal(t, mc.conf.AOFRewriteSize, int64(defaultRewriteSize))
+		assert.Equal(t, mc.codec(), Codec[string, int](JSONCodec[string, int]{}))
+	}
+	{
+		var path = filepath.Join(t.TempDir(), "cache.aof")
+		var mc = New[string, int](WithAOF(path, FsyncAlways), WithAOFRewriteSize(1024), WithCodec[string, int](failingCodec{}))
+		defer mc.Stop()
+		assert.Equal(t, mc.aof.policy, FsyncAlways)
+		assert.Equal(t, mc.aof.rewriteSize, int64(1024))
+		assert.Equal(t, mc.aof.codec, Codec[string, int](failingCodec{}))
+		assert.True(t, mc.storage[0].aof == mc.aof)
+	}
+}
+
+func TestWithAutoSnapshot(t *testing.T) {
+	var mc = New[string, int](WithAutoSnapshot("dir", time.Minute, 0))
+	defer mc.Stop()
+	assert.Equal(t, mc.conf.SnapshotDir, "dir")
+	assert.Equal(t, mc.conf.SnapshotInterval, time.Minute)
+	assert.Equal(t, mc.conf.SnapshotRetain, 1)
+}
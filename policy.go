@@ -0,0 +1,187 @@
+package memorycache
+
+import "math"
+
+// EvictionPolicy 淘汰策略
+type EvictionPolicy uint8
+
+const (
+	EvictionLRU   = EvictionPolicy(0) // 最近最少使用
+	EvictionFIFO  = EvictionPolicy(1) // 先进先出
+	EvictionLFU   = EvictionPolicy(2) // 最不经常使用
+	EvictionSIEVE = EvictionPolicy(3) // SIEVE
+)
+
+// Admission 准入策略
+type Admission uint8
+
+const (
+	AdmissionNone    = Admission(0) // 总是允许写入
+	AdmissionTinyLFU = Admission(1) // TinyLFU, 容量已满时比较新键和淘汰候选者的访问频率
+)
+
+// policy 维护链表中元素的顺序, 决定容量溢出时淘汰哪个元素
+type policy[K comparable, V any] interface {
+	// Insert 新元素已追加到链表尾部
+	Insert(ele *Element[K, V])
+
+	// Access 元素被访问
+	Access(ele *Element[K, V])
+
+	// Remove 元素即将从链表中删除
+	Remove(ele *Element[K, V])
+
+	// Victim 返回下一个被淘汰的元素
+	Victim() *Element[K, V]
+
+	// Peek 返回Victim将要返回的元素, 不改变淘汰策略的状态
+	Peek() *Element[K, V]
+}
+
+func newPolicy[K comparable, V any](p EvictionPolicy, q *deque[K, V]) policy[K, V] {
+	switch p {
+	case EvictionFIFO:
+		return &fifoPolicy[K, V]{List: q}
+	case EvictionLFU:
+		return &lfuPolicy[K, V]{List: q, tails: make(map[uint32]pointer)}
+	case EvictionSIEVE:
+		return &sievePolicy[K, V]{List: q}
+	default:
+		return &lruPolicy[K, V]{List: q}
+	}
+}
+
+// 最近被访问的元素移动到尾部, 淘汰头部元素
+type lruPolicy[K comparable, V any] struct {
+	List *deque[K, V]
+}
+
+func (c *lruPolicy[K, V]) Insert(ele *Element[K, V]) {}
+
+func (c *lruPolicy[K, V]) Access(ele *Element[K, V]) { c.List.MoveToBack(ele.addr) }
+
+func (c *lruPolicy[K, V]) Remove(ele *Element[K, V]) {}
+
+func (c *lruPolicy[K, V]) Victim() *Element[K, V] { return c.List.Front() }
+
+func (c *lruPolicy[K, V]) Peek() *Element[K, V] { return c.List.Front() }
+
+// 按插入顺序淘汰, 访问不改变顺序
+type fifoPolicy[K comparable, V any] struct {
+	List *deque[K, V]
+}
+
+func (c *fifoPolicy[K, V]) Insert(ele *Element[K, V]) {}
+
+func (c *fifoPolicy[K, V]) Access(ele *Element[K, V]) {}
+
+func (c *fifoPolicy[K, V]) Remove(ele *Element[K, V]) {}
+
+func (c *fifoPolicy[K, V]) Victim() *Element[K, V] { return c.List.Front() }
+
+func (c *fifoPolicy[K, V]) Peek() *Element[K, V] { return c.List.Front() }
+
+// 链表按访问频率升序排列, 相同频率的元素按访问先后排列, 淘汰头部元素.
+// tails记录每个频率分段的尾部, 所有操作都是O(1)的.
+type lfuPolicy[K comparable, V any] struct {
+	List  *deque[K, V]
+	tails map[uint32]pointer
+}
+
+func (c *lfuPolicy[K, V]) Insert(ele *Element[K, V]) {
+	ele.freq = 1
+	if tail, ok := c.tails[1]; ok {
+		c.List.MoveAfter(ele.addr, tail)
+	} else {
+		c.List.MoveToFront(ele.addr)
+	}
+	c.tails[1] = ele.addr
+}
+
+func (c *lfuPolicy[K, V]) Access(ele *Element[K, V]) {
+	var freq = ele.freq
+	if freq == math.MaxUint32 {
+		return
+	}
+
+	c.detach(ele)
+	if tail, ok := c.tails[freq+1]; ok {
+		c.List.MoveAfter(ele.addr, tail)
+	} else if tail, ok := c.tails[freq]; ok {
+		c.List.MoveAfter(ele.addr, tail)
+	}
+	ele.freq++
+	c.tails[ele.freq] = ele.addr
+}
+
+func (c *lfuPolicy[K, V]) Remove(ele *Element[K, V]) { c.detach(ele) }
+
+func (c *lfuPolicy[K, V]) Victim() *Element[K, V] { return c.List.Front() }
+
+func (c *lfuPolicy[K, V]) Peek() *Element[K, V] { return c.List.Front() }
+
+// 将元素移出所在频率分段的尾部
+func (c *lfuPolicy[K, V]) detach(ele *Element[K, V]) {
+	if c.tails[ele.freq] != ele.addr {
+		return
+	}
+	if prev := c.List.Get(ele.prev); prev != nil && prev.freq == ele.freq {
+		c.tails[ele.freq] = prev.addr
+	} else {
+		delete(c.tails, ele.freq)
+	}
+}
+
+// SIEVE: 访问只设置标记, 指针从头部向尾部扫描, 清除标记并淘汰第一个未标记的元素
+type sievePolicy[K comparable, V any] struct {
+	List *deque[K, V]
+	hand pointer
+}
+
+func (c *sievePolicy[K, V]) Insert(ele *Element[K, V]) { ele.freq = 0 }
+
+func (c *sievePolicy[K, V]) Access(ele *Element[K, V]) { ele.freq = 1 }
+
+func (c *sievePolicy[K, V]) Remove(ele *Element[K, V]) {
+	if c.hand == ele.addr {
+		c.hand = ele.next
+	}
+}
+
+func (c *sievePolicy[K, V]) Victim() *Element[K, V] {
+	var ele = c.List.Get(c.hand)
+	if ele == nil {
+		ele = c.List.Front()
+	}
+	for ele != nil && ele.freq > 0 {
+		ele.freq = 0
+		if ele = c.List.Get(ele.next); ele == nil {
+			ele = c.List.Front()
+		}
+	}
+	if ele != nil {
+		c.hand = ele.addr
+	}
+	return ele
+}
+
+// Peek 从指针位置开始查找第一个未标记的元素, 不清除标记也不移动指针.
+// 所有元素都被标记时, Victim清除一圈标记后回到起点, 因此返回起点元素.
+func (c *sievePolicy[K, V]) Peek() *Element[K, V] {
+	var start = c.List.Get(c.hand)
+	if start == nil {
+		start = c.List.Front()
+	}
+	for ele := start; ele != nil; {
+		if ele.freq == 0 {
+			return ele
+		}
+		if ele = c.List.Get(ele.next); ele == nil {
+			ele = c.List.Front()
+		}
+		if ele == start {
+			break
+		}
+	}
+	return start
+}
@@ -0,0 +1,194 @@
+package memorycache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lxzan/memorycache/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func getListKeys[K comparable, V any](q *deque[K, V]) []K {
+	var keys []K
+	q.Range(func(ele *Element[K, V]) bool {
+		keys = append(keys, ele.Key)
+		return true
+	})
+	return keys
+}
+
+func TestEvictionPolicy(t *testing.T) {
+	t.Run("lru", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(1), WithBucketSize(0, 3))
+		mc.Set("a", 1, time.Hour)
+		mc.Set("b", 1, time.Hour)
+		mc.Set("c", 1, time.Hour)
+		mc.Get("a")
+		mc.Set("d", 1, time.Hour)
+		assert.ElementsMatch(t, getKeys(mc), []string{"a", "c", "d"})
+	})
+
+	t.Run("fifo", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(1), WithBucketSize(0, 3), WithEvictionPolicy(EvictionFIFO))
+		mc.Set("a", 1, time.Hour)
+		mc.Set("b", 1, time.Hour)
+		mc.Set("c", 1, time.Hour)
+		mc.Get("a")
+		mc.Set("a", 2, time.Hour)
+		mc.Set("d", 1, time.Hour)
+		assert.ElementsMatch(t, getKeys(mc), []string{"b", "c", "d"})
+	})
+
+	t.Run("lfu", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(1), WithBucketSize(0, 3), WithEvictionPolicy(EvictionLFU))
+		mc.Set("a", 1, time.Hour)
+		mc.Set("b", 1, time.Hour)
+		mc.Set("c", 1, time.Hour)
+		mc.Get("a")
+		mc.Get("a")
+		mc.Get("b")
+		mc.Get("c")
+		mc.Get("c")
+		mc.Set("d", 1, time.Hour)
+		assert.ElementsMatch(t, getKeys(mc), []string{"a", "c", "d"})
+		mc.Set("e", 1, time.Hour)
+		assert.ElementsMatch(t, getKeys(mc), []string{"a", "c", "e"})
+		assert.Equal(t, getListKeys(mc.storage[0].List), []string{"e", "a", "c"})
+	})
+
+	t.Run("sieve", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(1), WithBucketSize(0, 3), WithEvictionPolicy(EvictionSIEVE))
+		mc.Set("a", 1, time.Hour)
+		mc.Set("b", 1, time.Hour)
+		mc.Set("c", 1, time.Hour)
+		mc.Get("a")
+		mc.Set("d", 1, time.Hour)
+		assert.ElementsMatch(t, getKeys(mc), []string{"a", "c", "d"})
+		mc.Get("d")
+		mc.Set("e", 1, time.Hour)
+		assert.ElementsMatch(t, getKeys(mc), []string{"a", "d", "e"})
+		mc.Set("f", 1, time.Hour)
+		assert.ElementsMatch(t, getKeys(mc), []string{"a", "d", "f"})
+	})
+}
+
+func TestEvictionPolicy_Random(t *testing.T) {
+	for _, p := range []EvictionPolicy{EvictionLRU, EvictionFIFO, EvictionLFU, EvictionSIEVE} {
+		t.Run(strconv.Itoa(int(p)), func(t *testing.T) {
+			const count = 10000
+			var mc = New[string, int](
+				WithBucketNum(4),
+				WithBucketSize(100, 200),
+				WithEvictionPolicy(p),
+			)
+			for i := 0; i < count; i++ {
+				var key = string(utils.AlphabetNumeric.Generate(2))
+				switch utils.AlphabetNumeric.Intn(4) {
+				case 0:
+					mc.Set(key, i, time.Hour)
+				case 1:
+					mc.Get(key)
+				case 2:
+					mc.GetWithTTL(key, time.Hour)
+				case 3:
+					mc.Delete(key)
+				}
+			}
+
+			for _, b := range mc.storage {
+				assert.LessOrEqual(t, b.List.Len(), 200)
+				assert.Equal(t, b.Heap.Len(), b.List.Len())
+				assert.True(t, isChained(b))
+				assert.Equal(t, b.Policy.Peek(), b.Policy.Victim())
+				if lfu, ok := b.Policy.(*lfuPolicy[string, int]); ok {
+					var last uint32 = 0
+					b.List.Range(func(ele *Element[string, int]) bool {
+						assert.LessOrEqual(t, last, ele.freq)
+						if next := b.List.Get(ele.next); next == nil || next.freq != ele.freq {
+							assert.Equal(t, lfu.tails[ele.freq], ele.addr)
+						}
+						last = ele.freq
+						return true
+					})
+				}
+			}
+		})
+	}
+}
+
+func TestAdmission(t *testing.T) {
+	// 热点数据被多次访问后, 大量只访问一次的键涌入
+	var scan = func(mc *MemoryCache[string, int]) {
+		for i := 0; i < 500; i++ {
+			mc.Set("hot"+strconv.Itoa(i%20), i, time.Hour)
+		}
+		for i := 0; i < 300; i++ {
+			mc.Set("cold"+strconv.Itoa(i), i, time.Hour)
+		}
+	}
+
+	t.Run("none", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(1), WithBucketSize(0, 100))
+		scan(mc)
+		_, ok := mc.Get("hot0")
+		assert.False(t, ok)
+	})
+
+	t.Run("tinylfu", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(1), WithBucketSize(0, 100), WithAdmission(AdmissionTinyLFU))
+		scan(mc)
+		for i := 0; i < 20; i++ {
+			_, ok := mc.Get("hot" + strconv.Itoa(i))
+			assert.True(t, ok)
+		}
+		assert.Equal(t, mc.Len(), 100)
+	})
+
+	t.Run("callback", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(1), WithBucketSize(0, 1), WithAdmission(AdmissionTinyLFU))
+		mc.Set("a", 1, time.Hour)
+		mc.Get("a")
+		mc.Get("a")
+
+		var reasons []Reason
+		mc.SetWithCallback("b", 2, time.Hour, func(ele *Element[string, int], reason Reason) {
+			assert.Equal(t, ele.Key, "b")
+			assert.Equal(t, ele.Value, 2)
+			reasons = append(reasons, reason)
+		})
+		v, exist := mc.GetOrCreateWithCallback("c", 3, time.Hour, func(ele *Element[string, int], reason Reason) {
+			reasons = append(reasons, reason)
+		})
+		assert.False(t, exist)
+		assert.Equal(t, v, 3)
+		assert.Equal(t, reasons, []Reason{ReasonEvicted, ReasonEvicted})
+		assert.ElementsMatch(t, getKeys(mc), []string{"a"})
+	})
+
+	t.Run("sieve", func(t *testing.T) {
+		var mc = New[string, int](
+			WithBucketNum(1),
+			WithBucketSize(0, 3),
+			WithEvictionPolicy(EvictionSIEVE),
+			WithAdmission(AdmissionTinyLFU),
+		)
+		for _, key := range []string{"a", "b", "c"} {
+			mc.Set(key, 1, time.Hour)
+			mc.Get(key)
+			mc.Get(key)
+		}
+		var b = mc.storage[0]
+		var sieve = b.Policy.(*sievePolicy[string, int])
+		var hand = sieve.hand
+
+		// 被拒绝的写入不清除访问标记, 也不移动指针
+		mc.Set("d", 1, time.Hour)
+		assert.ElementsMatch(t, getKeys(mc), []string{"a", "b", "c"})
+		assert.Equal(t, sieve.hand, hand)
+		b.List.Range(func(ele *Element[string, int]) bool {
+			assert.Equal(t, ele.freq, uint32(1))
+			return true
+		})
+	})
+}
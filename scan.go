@@ -0,0 +1,39 @@
+package memorycache
+
+// Scan 增量遍历键. cursor为0时从头开始, 返回的next为0时遍历结束. count为每次遍历的槽位数量的参考值.
+// 在整个遍历期间一直存在的键至少会被返回一次; 遍历期间新增或删除的键可能被返回, 也可能不被返回.
+// Incrementally iterate over keys. Start with cursor 0; iteration is complete when next is 0.
+// count is a hint for the number of slots visited per call. Keys present during the whole iteration are returned
+// at least once; keys added or removed during the iteration may or may not be returned.
+func (c *MemoryCache[K, V]) Scan(cursor uint64, count int) (keys []K, next uint64) {
+	if count <= 0 {
+		count = 10
+	}
+
+	// 游标的高32位为存储桶序号, 低32位为存储桶内的槽位
+	var index, slot = int(cursor >> 32), int(cursor & 0xFFFFFFFF)
+	for index < len(c.storage) && count > 0 {
+		var b = c.storage[index]
+		b.Lock()
+		var now = c.getTimestamp()
+		var elements = b.List.elements
+		for slot < len(elements) && count > 0 {
+			if ele := &elements[slot]; ele.addr != null && !ele.expired(now) {
+				keys = append(keys, ele.Key)
+			}
+			slot++
+			count--
+		}
+		var done = slot >= len(elements)
+		b.Unlock()
+
+		if done {
+			index, slot = index+1, 0
+		}
+	}
+
+	if index >= len(c.storage) {
+		return keys, 0
+	}
+	return keys, uint64(index)<<32 | uint64(slot)
+}
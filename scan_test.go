@@ -0,0 +1,58 @@
+package memorycache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCache_Scan(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		var mc = New[string, int]()
+		defer mc.Stop()
+		keys, next := mc.Scan(0, 100)
+		assert.Empty(t, keys)
+		assert.Equal(t, next, uint64(0))
+	})
+
+	t.Run("all keys", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(4), WithCachedTime(false))
+		defer mc.Stop()
+		var expected = map[string]bool{}
+		for i := 0; i < 1000; i++ {
+			var key = strconv.Itoa(i)
+			mc.Set(key, i, time.Hour)
+			expected[key] = true
+		}
+		mc.Set("x", 1, time.Millisecond)
+		time.Sleep(5 * time.Millisecond)
+		mc.Delete("0")
+		delete(expected, "0")
+
+		for _, count := range []int{0, 1, 7, 100, 10000} {
+			var seen = map[string]bool{}
+			var cursor uint64
+			for {
+				keys, next := mc.Scan(cursor, count)
+				for _, key := range keys {
+					seen[key] = true
+				}
+				if next == 0 {
+					break
+				}
+				cursor = next
+			}
+			assert.Equal(t, seen, expected)
+		}
+	})
+
+	t.Run("stale cursor", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(1))
+		defer mc.Stop()
+		keys, next := mc.Scan(1<<32, 10)
+		assert.Empty(t, keys)
+		assert.Equal(t, next, uint64(0))
+	})
+}
@@ -0,0 +1,455 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lxzan/memorycache"
+)
+
+// 连接状态
+type client struct {
+	server *Server
+	id     int64
+	r      *reader
+	w      *writer
+	quit   bool
+}
+
+// 命令处理函数, args不包含命令名
+type handler func(c *client, args [][]byte)
+
+// 命令表, 参数数量必须在[min, max]之间, max<0表示不限制
+var commands = map[string]struct {
+	min, max int
+	fn       handler
+}{
+	"ping":      {0, 1, cmdPing},
+	"echo":      {1, 1, cmdEcho},
+	"hello":     {0, -1, cmdHello},
+	"select":    {1, 1, cmdSelect},
+	"quit":      {0, 0, cmdQuit},
+	"command":   {0, -1, cmdCommand},
+	"get":       {1, 1, cmdGet},
+	"set":       {2, -1, cmdSet},
+	"setnx":     {2, 2, cmdSetNX},
+	"getset":    {2, 2, cmdGetSet},
+	"getdel":    {1, 1, cmdGetDel},
+	"del":       {1, -1, cmdDel},
+	"exists":    {1, -1, cmdExists},
+	"ttl":       {1, 1, cmdTTL},
+	"pttl":      {1, 1, cmdPTTL},
+	"expire":    {2, 2, cmdExpire},
+	"pexpire":   {2, 2, cmdPExpire},
+	"expireat":  {2, 2, cmdExpireAt},
+	"pexpireat": {2, 2, cmdPExpireAt},
+	"persist":   {1, 1, cmdPersist},
+	"mget":      {1, -1, cmdMGet},
+	"mset":      {2, -1, cmdMSet},
+	"scan":      {1, -1, cmdScan},
+	"dbsize":    {0, 0, cmdDBSize},
+	"flushdb":   {0, 1, cmdFlush},
+	"flushall":  {0, 1, cmdFlush},
+	"info":      {0, -1, cmdInfo},
+}
+
+func (c *client) execute(args [][]byte) {
+	var name = strings.ToLower(string(args[0]))
+	cmd, ok := commands[name]
+	if !ok {
+		c.w.Error(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+		return
+	}
+	args = args[1:]
+	if len(args) < cmd.min || (cmd.max >= 0 && len(args) > cmd.max) {
+		c.w.Error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+		return
+	}
+	cmd.fn(c, args)
+}
+
+func (c *client) cache() *memorycache.MemoryCache[string, []byte] {
+	return c.server.cache
+}
+
+func (c *client) errSyntax() { c.w.Error("ERR syntax error") }
+
+func (c *client) errInteger() { c.w.Error("ERR value is not an integer or out of range") }
+
+func parseInt(p []byte) (int64, bool) {
+	n, err := strconv.ParseInt(string(p), 10, 64)
+	return n, err == nil
+}
+
+func cmdPing(c *client, args [][]byte) {
+	if len(args) == 0 {
+		c.w.Simple("PONG")
+		return
+	}
+	c.w.Bulk(args[0])
+}
+
+func cmdEcho(c *client, args [][]byte) { c.w.Bulk(args[0]) }
+
+// HELLO [protover], 切换协议版本并返回服务器信息. 不支持认证.
+func cmdHello(c *client, args [][]byte) {
+	if len(args) > 0 {
+		proto, ok := parseInt(args[0])
+		if !ok || (proto != 2 && proto != 3) {
+			c.w.Error("NOPROTO unsupported protocol version")
+			return
+		}
+		if len(args) > 1 {
+			c.errSyntax()
+			return
+		}
+		c.w.proto = int(proto)
+	}
+
+	c.w.Map(6)
+	c.w.BulkString("server")
+	c.w.BulkString("memorycache")
+	c.w.BulkString("proto")
+	c.w.Int(int64(c.w.proto))
+	c.w.BulkString("id")
+	c.w.Int(c.id)
+	c.w.BulkString("mode")
+	c.w.BulkString("standalone")
+	c.w.BulkString("role")
+	c.w.BulkString("master")
+	c.w.BulkString("modules")
+	c.w.Array(0)
+}
+
+func cmdSelect(c *client, args [][]byte) {
+	if string(args[0]) != "0" {
+		c.w.Error("ERR DB index is out of range")
+		return
+	}
+	c.w.Simple("OK")
+}
+
+func cmdQuit(c *client, args [][]byte) {
+	c.quit = true
+	c.w.Simple("OK")
+}
+
+// 客户端启动时会查询命令文档, 返回空列表即可
+func cmdCommand(c *client, args [][]byte) { c.w.Array(0) }
+
+func cmdGet(c *client, args [][]byte) {
+	if v, ok := c.cache().Get(string(args[0])); ok {
+		c.w.Bulk(v)
+		return
+	}
+	c.w.Null()
+}
+
+// SET key value [EX seconds | PX milliseconds] [NX | XX]
+func cmdSet(c *client, args [][]byte) {
+	var key, value = string(args[0]), args[1]
+	var ttl time.Duration
+	var nx, xx bool
+	for i := 2; i < len(args); i++ {
+		switch strings.ToLower(string(args[i])) {
+		case "nx":
+			nx = true
+		case "xx":
+			xx = true
+		case "ex", "px":
+			if ttl != 0 || i+1 >= len(args) {
+				c.errSyntax()
+				return
+			}
+			n, ok := parseInt(args[i+1])
+			if !ok {
+				c.errInteger()
+				return
+			}
+			var unit = time.Second
+			if args[i][0]|0x20 == 'p' {
+				unit = time.Millisecond
+			}
+			if ttl, ok = toDuration(n, unit); !ok || n <= 0 {
+				c.w.Error("ERR invalid expire time in 'set' command")
+				return
+			}
+			i++
+		default:
+			c.errSyntax()
+			return
+		}
+	}
+	if nx && xx {
+		c.errSyntax()
+		return
+	}
+
+	var stored = true
+	switch {
+	case nx:
+		stored = c.cache().SetIfAbsent(key, value, ttl)
+	case xx:
+		stored = c.cache().SetIfPresent(key, value, ttl)
+	default:
+		c.cache().Set(key, value, ttl)
+	}
+	if stored {
+		c.w.Simple("OK")
+	} else {
+		c.w.Null()
+	}
+}
+
+func cmdSetNX(c *client, args [][]byte) {
+	c.w.Bool(c.cache().SetIfAbsent(string(args[0]), args[1], 0))
+}
+
+// GETSET key value, 写入新值并移除过期时间, 返回旧值
+func cmdGetSet(c *client, args [][]byte) {
+	if v, ok, _ := c.cache().Swap(string(args[0]), args[1], 0); ok {
+		c.w.Bulk(v)
+		return
+	}
+	c.w.Null()
+}
+
+func cmdGetDel(c *client, args [][]byte) {
+	if v, ok := c.cache().GetAndDelete(string(args[0])); ok {
+		c.w.Bulk(v)
+		return
+	}
+	c.w.Null()
+}
+
+// 参数转换为键列表
+func keysOf(args [][]byte) []string {
+	var keys = make([]string, 0, len(args))
+	for _, item := range args {
+		keys = append(keys, string(item))
+	}
+	return keys
+}
+
+func cmdDel(c *client, args [][]byte) {
+	c.w.Int(int64(c.cache().DeleteMany(keysOf(args))))
+}
+
+// EXISTS 不记录命中统计, 也不影响淘汰顺序. 重复的键重复计数.
+func cmdExists(c *client, args [][]byte) {
+	var n int64
+	for _, item := range args {
+		if _, _, ok := c.cache().Peek(string(item)); ok {
+			n++
+		}
+	}
+	c.w.Int(n)
+}
+
+// 剩余存活时间, 毫秒. 键不存在返回-2, 永不过期返回-1.
+func (c *client) pttl(key string) int64 {
+	d, ok := c.cache().TTL(key)
+	if !ok {
+		return -2
+	}
+	if d == memorycache.NoExpiration {
+		return -1
+	}
+	return d.Milliseconds()
+}
+
+func cmdTTL(c *client, args [][]byte) {
+	var ms = c.pttl(string(args[0]))
+	if ms > 0 {
+		ms = (ms + 500) / 1000
+	}
+	c.w.Int(ms)
+}
+
+func cmdPTTL(c *client, args [][]byte) { c.w.Int(c.pttl(string(args[0]))) }
+
+// 将过期时间参数转换为 time.Duration, 超出范围时返回false
+func toDuration(n int64, unit time.Duration) (time.Duration, bool) {
+	if n > math.MaxInt64/int64(unit) || n < math.MinInt64/int64(unit) {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+// 将时间戳参数转换为时间, 对应的Unix毫秒时间戳超出int64范围时返回false
+func toTime(n int64, unit time.Duration) (time.Time, bool) {
+	var scale = int64(unit / time.Millisecond)
+	if n >= math.MaxInt64/scale || n <= math.MinInt64/scale {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(n * scale), true
+}
+
+func (c *client) expire(name string, args [][]byte, unit time.Duration) {
+	n, ok := parseInt(args[1])
+	if !ok {
+		c.errInteger()
+		return
+	}
+	d, ok := toDuration(n, unit)
+	if !ok {
+		c.w.Error("ERR invalid expire time in '" + name + "' command")
+		return
+	}
+
+	var key = string(args[0])
+
+	// 非正数的过期时间立即删除键
+	var exist bool
+	if n <= 0 {
+		exist = c.cache().Delete(key)
+	} else {
+		exist = c.cache().Expire(key, d)
+	}
+	c.w.Bool(exist)
+}
+
+func cmdExpire(c *client, args [][]byte) { c.expire("expire", args, time.Second) }
+
+func cmdPExpire(c *client, args [][]byte) { c.expire("pexpire", args, time.Millisecond) }
+
+// EXPIREAT/PEXPIREAT key timestamp, 时间戳已经过去时删除键
+func (c *client) expireAt(name string, args [][]byte, unit time.Duration) {
+	n, ok := parseInt(args[1])
+	if !ok {
+		c.errInteger()
+		return
+	}
+	t, ok := toTime(n, unit)
+	if !ok {
+		c.w.Error("ERR invalid expire time in '" + name + "' command")
+		return
+	}
+	c.w.Bool(c.cache().ExpireAt(string(args[0]), t))
+}
+
+func cmdExpireAt(c *client, args [][]byte) { c.expireAt("expireat", args, time.Second) }
+
+func cmdPExpireAt(c *client, args [][]byte) { c.expireAt("pexpireat", args, time.Millisecond) }
+
+func cmdPersist(c *client, args [][]byte) {
+	c.w.Bool(c.cache().Persist(string(args[0])))
+}
+
+func cmdMGet(c *client, args [][]byte) {
+	var keys = keysOf(args)
+	var values = c.cache().GetMany(keys)
+	c.w.Array(len(keys))
+	for _, key := range keys {
+		if v, ok := values[key]; ok {
+			c.w.Bulk(v)
+		} else {
+			c.w.Null()
+		}
+	}
+}
+
+// MSET 按存储桶批量写入键值, 不保证多个存储桶之间的原子性
+func cmdMSet(c *client, args [][]byte) {
+	if len(args)%2 != 0 {
+		c.w.Error("ERR wrong number of arguments for 'mset' command")
+		return
+	}
+	var entries = make([]memorycache.Entry[string, []byte], 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		entries = append(entries, memorycache.Entry[string, []byte]{Key: string(args[i]), Value: args[i+1]})
+	}
+	c.cache().SetMany(entries, 0)
+	c.w.Simple("OK")
+}
+
+// SCAN cursor [MATCH pattern] [COUNT count]
+func cmdScan(c *client, args [][]byte) {
+	cursor, err := strconv.ParseUint(string(args[0]), 10, 64)
+	if err != nil {
+		c.w.Error("ERR invalid cursor")
+		return
+	}
+	var pattern = ""
+	var count int64 = 10
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			c.errSyntax()
+			return
+		}
+		switch strings.ToLower(string(args[i])) {
+		case "match":
+			pattern = string(args[i+1])
+		case "count":
+			n, ok := parseInt(args[i+1])
+			if !ok {
+				c.errInteger()
+				return
+			}
+			if n < 1 {
+				c.errSyntax()
+				return
+			}
+			count = n
+		default:
+			c.errSyntax()
+			return
+		}
+	}
+
+	keys, next := c.cache().Scan(cursor, int(count))
+	if pattern != "" && pattern != "*" {
+		var list = keys[:0]
+		for _, key := range keys {
+			if match(pattern, key) {
+				list = append(list, key)
+			}
+		}
+		keys = list
+	}
+
+	c.w.Array(2)
+	c.w.BulkString(strconv.FormatUint(next, 10))
+	c.w.Array(len(keys))
+	for _, key := range keys {
+		c.w.BulkString(key)
+	}
+}
+
+func cmdDBSize(c *client, args [][]byte) { c.w.Int(int64(c.cache().Len())) }
+
+// FLUSHDB [ASYNC | SYNC], 总是同步清空
+func cmdFlush(c *client, args [][]byte) {
+	if len(args) == 1 {
+		if mode := strings.ToLower(string(args[0])); mode != "async" && mode != "sync" {
+			c.errSyntax()
+			return
+		}
+	}
+	c.cache().Clear()
+	c.w.Simple("OK")
+}
+
+// INFO [section], 返回服务器, 统计和键空间信息
+func cmdInfo(c *client, args [][]byte) {
+	var s = c.server
+	var stats = c.cache().Stats()
+	var b strings.Builder
+	b.WriteString("# Server\r\n")
+	b.WriteString("server:memorycache\r\n")
+	fmt.Fprintf(&b, "uptime_in_seconds:%d\r\n", int64(time.Since(s.started).Seconds()))
+	b.WriteString("\r\n# Clients\r\n")
+	fmt.Fprintf(&b, "connected_clients:%d\r\n", s.base.Clients())
+	b.WriteString("\r\n# Stats\r\n")
+	fmt.Fprintf(&b, "total_commands_processed:%d\r\n", s.commands.Load())
+	fmt.Fprintf(&b, "keyspace_hits:%d\r\n", stats.Hits)
+	fmt.Fprintf(&b, "keyspace_misses:%d\r\n", stats.Misses)
+	fmt.Fprintf(&b, "expired_keys:%d\r\n", stats.Evictions(memorycache.ReasonExpired))
+	fmt.Fprintf(&b, "evicted_keys:%d\r\n", stats.Evictions(memorycache.ReasonEvicted))
+	b.WriteString("\r\n# Keyspace\r\n")
+	fmt.Fprintf(&b, "db0:keys=%d\r\n", c.cache().Len())
+	c.w.BulkString(b.String())
+}
@@ -0,0 +1,77 @@
+package server
+
+// 按Redis的glob规则匹配: * 任意字符串, ? 任意单个字符, [abc] [^a] [a-z] 字符集合, \x 转义
+func match(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if match(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			var end, ok = matchClass(pattern, s[0])
+			if !ok {
+				return false
+			}
+			pattern, s = pattern[end:], s[1:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		}
+	}
+	return len(s) == 0
+}
+
+// 匹配字符集合, 返回集合结束后的位置和是否匹配. 没有右括号时集合延伸到模式末尾.
+func matchClass(pattern string, ch byte) (end int, ok bool) {
+	var i = 1
+	var negate = i < len(pattern) && pattern[i] == '^'
+	if negate {
+		i++
+	}
+	var matched = false
+	for ; i < len(pattern) && pattern[i] != ']'; i++ {
+		switch {
+		case pattern[i] == '\\' && i+1 < len(pattern):
+			i++
+			matched = matched || pattern[i] == ch
+		case i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']':
+			var lo, hi = pattern[i], pattern[i+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			matched = matched || (ch >= lo && ch <= hi)
+			i += 2
+		default:
+			matched = matched || pattern[i] == ch
+		}
+	}
+	if i < len(pattern) {
+		i++
+	}
+	return i, matched != negate
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/lxzan/dao/algo"
+)
+
+const (
+	maxBulkLen  = 512 << 20 // 单个参数的最大长度
+	maxArrayLen = 1 << 20   // 单个命令的最大参数数量
+	bulkChunk   = 64 << 10  // 读取参数时每次分配的最大长度
+)
+
+// 协议错误, 回复后关闭连接
+type protocolError string
+
+func (c protocolError) Error() string { return "Protocol error: " + string(c) }
+
+// RESP请求解析器, 支持多条批量字符串组成的数组和内联命令
+type reader struct {
+	r *bufio.Reader
+}
+
+func (c *reader) readLine() ([]byte, error) {
+	line, err := c.r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return nil, protocolError("too big inline request")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(line, "\r\n"), nil
+}
+
+// 读取 *N 或 $N 形式的长度, 范围为[-1, max]
+func (c *reader) readLength(prefix byte, max int) (int, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return 0, err
+	}
+	if len(line) == 0 || line[0] != prefix {
+		return 0, protocolError("expected '" + string(prefix) + "'")
+	}
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n < -1 || n > max {
+		return 0, protocolError("invalid length")
+	}
+	return n, nil
+}
+
+// 读取长度为size的批量字符串和结尾的CRLF. 内存随收到的数据按块增长, 不会按声明的长度预先分配.
+func (c *reader) readBulk(size int) ([]byte, error) {
+	var p = make([]byte, 0, algo.Min(size, bulkChunk))
+	for len(p) < size {
+		if len(p) == cap(p) {
+			p = append(make([]byte, 0, algo.Min(size, 2*cap(p))), p...)
+		}
+		n, err := io.ReadFull(c.r, p[len(p):cap(p)])
+		p = p[:len(p)+n]
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var crlf [2]byte
+	if _, err := io.ReadFull(c.r, crlf[:]); err != nil {
+		return nil, err
+	}
+	if crlf != [2]byte{'\r', '\n'} {
+		return nil, protocolError("expected CRLF")
+	}
+	return p, nil
+}
+
+// ReadCommand 读取一条命令. 空的内联命令和空数组(包括 *-1)返回长度为0的参数列表.
+func (c *reader) ReadCommand() ([][]byte, error) {
+	b, err := c.r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if b[0] != '*' {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		return bytes.Fields(line), nil
+	}
+
+	n, err := c.readLength('*', maxArrayLen)
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+	var args = make([][]byte, 0, algo.Min(n, 64))
+	for i := 0; i < n; i++ {
+		size, err := c.readLength('$', maxBulkLen)
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, protocolError("invalid bulk length")
+		}
+		p, err := c.readBulk(size)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, p)
+	}
+	return args, nil
+}
+
+// RESP回复编码器. proto为3时使用RESP3的空值和映射类型.
+type writer struct {
+	w     *bufio.Writer
+	proto int
+	buf   []byte
+}
+
+func (c *writer) line(prefix byte, s string) {
+	_ = c.w.WriteByte(prefix)
+	_, _ = c.w.WriteString(s)
+	_, _ = c.w.WriteString("\r\n")
+}
+
+func (c *writer) length(prefix byte, n int) {
+	c.buf = append(append(c.buf[:0], prefix), strconv.Itoa(n)...)
+	c.buf = append(c.buf, '\r', '\n')
+	_, _ = c.w.Write(c.buf)
+}
+
+func (c *writer) Simple(s string) { c.line('+', s) }
+
+func (c *writer) Error(s string) { c.line('-', s) }
+
+func (c *writer) Int(n int64) { c.line(':', strconv.FormatInt(n, 10)) }
+
+// Bool 以整数1或0回复
+func (c *writer) Bool(ok bool) {
+	if ok {
+		c.Int(1)
+		return
+	}
+	c.Int(0)
+}
+
+func (c *writer) Bulk(p []byte) {
+	c.length('$', len(p))
+	_, _ = c.w.Write(p)
+	_, _ = c.w.WriteString("\r\n")
+}
+
+func (c *writer) BulkString(s string) {
+	c.length('$', len(s))
+	_, _ = c.w.WriteString(s)
+	_, _ = c.w.WriteString("\r\n")
+}
+
+func (c *writer) Null() {
+	if c.proto == 3 {
+		_, _ = c.w.WriteString("_\r\n")
+		return
+	}
+	_, _ = c.w.WriteString("$-1\r\n")
+}
+
+func (c *writer) Array(n int) { c.length('*', n) }
+
+// Map 写入包含n个键值对的映射头, RESP2中为长度2n的数组
+func (c *writer) Map(n int) {
+	if c.proto == 3 {
+		c.length('%', n)
+		return
+	}
+	c.length('*', 2*n)
+}
+
+func (c *writer) Flush() error { return c.w.Flush() }
@@ -0,0 +1,109 @@
+// Package server 通过Redis协议(RESP2/RESP3)提供 MemoryCache[string, []byte] 服务.
+// Package server serves a MemoryCache[string, []byte] over the Redis protocol (RESP2/RESP3).
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/lxzan/memorycache"
+	"github.com/lxzan/memorycache/internal/netserver"
+)
+
+// ErrServerClosed Serve 在 Close 之后返回的错误
+// Error returned by Serve after Close
+var ErrServerClosed = errors.New("server: server closed")
+
+// Server Redis协议服务器. 所有命令直接使用缓存的原子操作, SET NX/XX 使用条件写入, DEL, MGET 和 MSET 按存储桶批量执行.
+// Redis protocol server. Every command maps onto the atomic operations of the cache: SET NX/XX uses the conditional writes,
+// and DEL, MGET and MSET are batched by bucket.
+type Server struct {
+	base  *netserver.Server
+	cache *memorycache.MemoryCache[string, []byte]
+
+	started  time.Time
+	commands atomic.Uint64
+	clientID atomic.Int64
+}
+
+// New 创建服务器
+// Create a server
+func New(cache *memorycache.MemoryCache[string, []byte]) *Server {
+	var c = &Server{
+		cache:   cache,
+		started: time.Now(),
+	}
+	c.base = netserver.New(c.serveConn, ErrServerClosed)
+	return c
+}
+
+// ListenAndServe 监听TCP地址并提供服务
+// Listen on the TCP address and serve
+func (c *Server) ListenAndServe(addr string) error {
+	return c.base.ListenAndServe(addr)
+}
+
+// Serve 接受连接并提供服务, 直到 Close 被调用. 总是返回非nil的错误.
+// Accept connections and serve them until Close is called. Always returns a non-nil error.
+func (c *Server) Serve(ln net.Listener) error {
+	return c.base.Serve(ln)
+}
+
+// Close 关闭所有监听器和连接, 并等待连接处理协程退出
+// Close all listeners and connections, and wait for the connection goroutines to exit
+func (c *Server) Close() error {
+	c.base.Close()
+	return nil
+}
+
+// 处理一个连接, 返回后连接被关闭
+func (c *Server) serveConn(conn net.Conn) {
+	var cli = &client{
+		server: c,
+		id:     c.clientID.Add(1),
+		r:      &reader{r: bufio.NewReaderSize(conn, 16*1024)},
+		w:      &writer{w: bufio.NewWriter(conn), proto: 2},
+	}
+	for !cli.quit {
+		args, err := cli.r.ReadCommand()
+		if err != nil {
+			var perr protocolError
+			if errors.As(err, &perr) {
+				cli.w.Error("ERR " + perr.Error())
+				_ = cli.w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		c.commands.Add(1)
+		if !cli.safeExecute(args) {
+			return
+		}
+
+		// 流水线中的命令处理完后再统一写出
+		if cli.r.r.Buffered() == 0 || cli.quit {
+			if err := cli.w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// 执行命令. 命令处理中的panic回复错误后关闭当前连接, 不影响其他连接.
+func (c *client) safeExecute(args [][]byte) (ok bool) {
+	defer func() {
+		if e := recover(); e != nil {
+			c.w.Error(fmt.Sprintf("ERR internal error: %v", e))
+			_ = c.w.Flush()
+			ok = false
+		}
+	}()
+	c.execute(args)
+	return true
+}
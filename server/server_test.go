@@ -0,0 +1,421 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lxzan/memorycache"
+	"github.com/stretchr/testify/assert"
+)
+
+// 最小的RESP客户端, 错误回复以 error 类型返回, 空值以 nil 返回
+type testClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (c *testClient) Do(args ...string) any {
+	c.Send(args...)
+	return c.Read()
+}
+
+func (c *testClient) Send(args ...string) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	_, _ = c.conn.Write([]byte(b.String()))
+}
+
+func (c *testClient) Read() any {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	switch line[0] {
+	case '+':
+		return line[1:]
+	case '-':
+		return fmt.Errorf("%s", line[1:])
+	case ':':
+		n, _ := strconv.ParseInt(line[1:], 10, 64)
+		return n
+	case '_':
+		return nil
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil
+		}
+		var p = make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, p); err != nil {
+			return err
+		}
+		return string(p[:n])
+	case '*', '%':
+		n, _ := strconv.Atoi(line[1:])
+		if line[0] == '%' {
+			n *= 2
+		}
+		var list = make([]any, 0, n)
+		for i := 0; i < n; i++ {
+			list = append(list, c.Read())
+		}
+		return list
+	default:
+		return fmt.Errorf("unexpected reply %q", line)
+	}
+}
+
+func newTestServer(t *testing.T, options ...memorycache.Option) (*Server, *memorycache.MemoryCache[string, []byte], string) {
+	var cache = memorycache.New[string, []byte](append([]memorycache.Option{memorycache.WithCachedTime(false)}, options...)...)
+	var srv = New(cache)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() {
+		_ = srv.Close()
+		cache.Stop()
+	})
+	return srv, cache, ln.Addr().String()
+}
+
+func dial(t *testing.T, addr string) *testClient {
+	conn, err := net.Dial("tcp", addr)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return &testClient{conn: conn, r: bufio.NewReader(conn)}
+}
+
+func isError(v any, prefix string) bool {
+	err, ok := v.(error)
+	return ok && strings.HasPrefix(err.Error(), prefix)
+}
+
+func TestServer_Basic(t *testing.T) {
+	t.Run("", func(t *testing.T) {
+		_, _, addr := newTestServer(t)
+		var cli = dial(t, addr)
+		assert.Equal(t, cli.Do("PING"), "PONG")
+		assert.Equal(t, cli.Do("ping", "hi"), "hi")
+		assert.Equal(t, cli.Do("ECHO", "hello"), "hello")
+		assert.Equal(t, cli.Do("SELECT", "0"), "OK")
+		assert.True(t, isError(cli.Do("SELECT", "1"), "ERR"))
+		assert.Equal(t, cli.Do("COMMAND", "DOCS"), []any{})
+		assert.True(t, isError(cli.Do("NOPE"), "ERR unknown command"))
+		assert.True(t, isError(cli.Do("GET"), "ERR wrong number of arguments"))
+		assert.True(t, isError(cli.Do("QUIT", "x"), "ERR wrong number of arguments"))
+	})
+
+	t.Run("get set", func(t *testing.T) {
+		_, cache, addr := newTestServer(t)
+		var cli = dial(t, addr)
+		assert.Nil(t, cli.Do("GET", "a"))
+		assert.Equal(t, cli.Do("SET", "a", "1"), "OK")
+		assert.Equal(t, cli.Do("GET", "a"), "1")
+		assert.Nil(t, cli.Do("SET", "a", "2", "NX"))
+		assert.Equal(t, cli.Do("GET", "a"), "1")
+		assert.Nil(t, cli.Do("SET", "b", "2", "XX"))
+		assert.Equal(t, cli.Do("SET", "a", "3", "xx", "ex", "100"), "OK")
+		assert.Equal(t, cli.Do("SET", "b", "4", "NX", "PX", "50"), "OK")
+		assert.Equal(t, cli.Do("GET", "b"), "4")
+		time.Sleep(100 * time.Millisecond)
+		assert.Nil(t, cli.Do("GET", "b"))
+
+		assert.True(t, isError(cli.Do("SET", "a", "1", "NX", "XX"), "ERR syntax error"))
+		assert.True(t, isError(cli.Do("SET", "a", "1", "EX"), "ERR syntax error"))
+		assert.True(t, isError(cli.Do("SET", "a", "1", "EX", "x"), "ERR value is not an integer"))
+		assert.True(t, isError(cli.Do("SET", "a", "1", "EX", "0"), "ERR invalid expire time"))
+		assert.True(t, isError(cli.Do("SET", "a", "1", "EX", "1", "PX", "1"), "ERR syntax error"))
+		assert.True(t, isError(cli.Do("SET", "a", "1", "FOO"), "ERR syntax error"))
+
+		v, _ := cache.Get("a")
+		assert.Equal(t, string(v), "3")
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		// 超出开销上限的条件写入回复nil
+		_, _, addr := newTestServer(t, memorycache.WithBucketNum(1), memorycache.WithMaxCost(10),
+			memorycache.WithWeigher(func(key string, value []byte) int64 { return int64(len(value)) }))
+		var cli = dial(t, addr)
+		assert.Nil(t, cli.Do("SET", "a", strings.Repeat("x", 20), "NX"))
+		assert.Equal(t, cli.Do("SETNX", "a", strings.Repeat("x", 20)), int64(0))
+		assert.Equal(t, cli.Do("SET", "a", "1", "NX"), "OK")
+		assert.Nil(t, cli.Do("SET", "a", strings.Repeat("x", 20), "XX"))
+		assert.Nil(t, cli.Do("GET", "a"))
+	})
+
+	t.Run("conditional", func(t *testing.T) {
+		_, cache, addr := newTestServer(t)
+		var cli = dial(t, addr)
+		assert.Equal(t, cli.Do("SETNX", "a", "1"), int64(1))
+		assert.Equal(t, cli.Do("SETNX", "a", "2"), int64(0))
+		assert.Nil(t, cli.Do("GETSET", "b", "1"))
+		assert.Equal(t, cli.Do("SET", "a", "3", "EX", "100"), "OK")
+		assert.Equal(t, cli.Do("GETSET", "a", "4"), "3")
+		_, expireAt, _ := cache.Peek("a")
+		assert.True(t, expireAt.IsZero())
+		assert.Equal(t, cli.Do("GETDEL", "a"), "4")
+		assert.Nil(t, cli.Do("GETDEL", "a"))
+		assert.Equal(t, cli.Do("EXISTS", "a"), int64(0))
+	})
+
+	t.Run("del exists", func(t *testing.T) {
+		_, _, addr := newTestServer(t)
+		var cli = dial(t, addr)
+		assert.Equal(t, cli.Do("MSET", "a", "1", "b", "2"), "OK")
+		assert.True(t, isError(cli.Do("MSET", "a", "1", "b"), "ERR wrong number of arguments"))
+		assert.Equal(t, cli.Do("MGET", "a", "c", "b"), []any{"1", nil, "2"})
+		assert.Equal(t, cli.Do("EXISTS", "a", "b", "c", "a"), int64(3))
+		assert.Equal(t, cli.Do("DBSIZE"), int64(2))
+		assert.Equal(t, cli.Do("MGET", "b", "b"), []any{"2", "2"})
+		assert.Equal(t, cli.Do("DEL", "a", "c"), int64(1))
+		assert.Equal(t, cli.Do("EXISTS", "a"), int64(0))
+		assert.Equal(t, cli.Do("DEL", "b", "b"), int64(1))
+		assert.Equal(t, cli.Do("FLUSHDB"), "OK")
+		assert.Equal(t, cli.Do("DBSIZE"), int64(0))
+		assert.Equal(t, cli.Do("FLUSHALL", "ASYNC"), "OK")
+		assert.True(t, isError(cli.Do("FLUSHALL", "LATER"), "ERR syntax error"))
+	})
+
+	t.Run("expire", func(t *testing.T) {
+		_, _, addr := newTestServer(t)
+		var cli = dial(t, addr)
+		assert.Equal(t, cli.Do("TTL", "a"), int64(-2))
+		assert.Equal(t, cli.Do("EXPIRE", "a", "10"), int64(0))
+		assert.Equal(t, cli.Do("SET", "a", "1"), "OK")
+		assert.Equal(t, cli.Do("TTL", "a"), int64(-1))
+		assert.Equal(t, cli.Do("PERSIST", "a"), int64(0))
+		assert.Equal(t, cli.Do("EXPIRE", "a", "10"), int64(1))
+		assert.Equal(t, cli.Do("TTL", "a"), int64(10))
+		assert.Equal(t, cli.Do("PERSIST", "a"), int64(1))
+		assert.Equal(t, cli.Do("PTTL", "a"), int64(-1))
+		assert.Equal(t, cli.Do("PEXPIRE", "a", "50"), int64(1))
+		time.Sleep(100 * time.Millisecond)
+		assert.Nil(t, cli.Do("GET", "a"))
+
+		assert.Equal(t, cli.Do("SET", "c", "1"), "OK")
+		assert.Equal(t, cli.Do("EXPIREAT", "c", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)), int64(1))
+		var ttl = cli.Do("TTL", "c").(int64)
+		assert.True(t, ttl > 3590 && ttl <= 3600)
+		assert.Equal(t, cli.Do("PEXPIREAT", "c", strconv.FormatInt(time.Now().Add(time.Minute).UnixMilli(), 10)), int64(1))
+		ttl = cli.Do("PTTL", "c").(int64)
+		assert.True(t, ttl > 59000 && ttl <= 60000)
+		assert.Equal(t, cli.Do("EXPIREAT", "c", "10000000000"), int64(1))
+		ttl = cli.Do("TTL", "c").(int64)
+		assert.True(t, ttl > 10000000000-time.Now().Unix()-10)
+		assert.True(t, isError(cli.Do("EXPIREAT", "c", "9223372036854775807"), "ERR invalid expire time in 'expireat' command"))
+		assert.True(t, isError(cli.Do("PEXPIREAT", "c", "9223372036854775807"), "ERR invalid expire time"))
+		assert.True(t, isError(cli.Do("EXPIRE", "c", "9223372036854775807"), "ERR invalid expire time in 'expire' command"))
+		assert.True(t, isError(cli.Do("SET", "c", "1", "EX", "9223372036854775807"), "ERR invalid expire time in 'set' command"))
+		assert.Equal(t, cli.Do("EXPIREAT", "c", "1"), int64(1))
+		assert.Equal(t, cli.Do("EXISTS", "c"), int64(0))
+		assert.Equal(t, cli.Do("EXPIREAT", "c", "1"), int64(0))
+
+		assert.Equal(t, cli.Do("SET", "b", "1"), "OK")
+		assert.Equal(t, cli.Do("EXPIRE", "b", "-1"), int64(1))
+		assert.Equal(t, cli.Do("EXISTS", "b"), int64(0))
+		assert.True(t, isError(cli.Do("EXPIRE", "b", "x"), "ERR value is not an integer"))
+
+		assert.Equal(t, cli.Do("SET", "d", "1", "EX", "100"), "OK")
+		assert.Equal(t, cli.Do("TTL", "d"), int64(100))
+		assert.Equal(t, cli.Do("GETSET", "d", "2"), "1")
+		assert.Equal(t, cli.Do("TTL", "d"), int64(-1))
+	})
+
+	t.Run("scan", func(t *testing.T) {
+		_, _, addr := newTestServer(t)
+		var cli = dial(t, addr)
+		for i := 0; i < 100; i++ {
+			cli.Send("SET", fmt.Sprintf("user:%d", i), "1")
+			cli.Send("SET", fmt.Sprintf("item:%d", i), "1")
+		}
+		for i := 0; i < 200; i++ {
+			assert.Equal(t, cli.Read(), "OK")
+		}
+
+		var keys = make(map[string]bool)
+		var cursor = "0"
+		for {
+			var reply = cli.Do("SCAN", cursor, "MATCH", "user:*", "COUNT", "7").([]any)
+			for _, key := range reply[1].([]any) {
+				assert.True(t, strings.HasPrefix(key.(string), "user:"))
+				keys[key.(string)] = true
+			}
+			cursor = reply[0].(string)
+			if cursor == "0" {
+				break
+			}
+		}
+		assert.Equal(t, len(keys), 100)
+
+		assert.True(t, isError(cli.Do("SCAN", "x"), "ERR invalid cursor"))
+		assert.True(t, isError(cli.Do("SCAN", "0", "COUNT", "0"), "ERR syntax error"))
+		assert.True(t, isError(cli.Do("SCAN", "0", "MATCH"), "ERR syntax error"))
+	})
+
+	t.Run("info", func(t *testing.T) {
+		_, _, addr := newTestServer(t)
+		var cli = dial(t, addr)
+		assert.Equal(t, cli.Do("SET", "a", "1"), "OK")
+		var info = cli.Do("INFO").(string)
+		assert.Contains(t, info, "connected_clients:1\r\n")
+		assert.Contains(t, info, "db0:keys=1\r\n")
+	})
+}
+
+func TestServer_Protocol(t *testing.T) {
+	t.Run("hello", func(t *testing.T) {
+		_, _, addr := newTestServer(t)
+		var cli = dial(t, addr)
+		var reply = cli.Do("HELLO", "3").([]any)
+		assert.Equal(t, len(reply), 12)
+		assert.Equal(t, reply[2:4], []any{"proto", int64(3)})
+		assert.Nil(t, cli.Do("GET", "a"))
+		assert.True(t, isError(cli.Do("HELLO", "4"), "NOPROTO"))
+		assert.True(t, isError(cli.Do("HELLO", "2", "AUTH"), "ERR syntax error"))
+		assert.Equal(t, cli.Do("HELLO", "2").([]any)[3], int64(2))
+	})
+
+	t.Run("inline", func(t *testing.T) {
+		_, _, addr := newTestServer(t)
+		var cli = dial(t, addr)
+		_, _ = cli.conn.Write([]byte("SET a hello\r\n\r\nGET a\r\n"))
+		assert.Equal(t, cli.Read(), "OK")
+		assert.Equal(t, cli.Read(), "hello")
+	})
+
+	t.Run("pipeline", func(t *testing.T) {
+		_, _, addr := newTestServer(t)
+		var cli = dial(t, addr)
+		var b strings.Builder
+		for i := 0; i < 1000; i++ {
+			fmt.Fprintf(&b, "*3\r\n$3\r\nSET\r\n$%d\r\n%d\r\n$1\r\nv\r\n", len(strconv.Itoa(i)), i)
+		}
+		_, _ = cli.conn.Write([]byte(b.String()))
+		for i := 0; i < 1000; i++ {
+			assert.Equal(t, cli.Read(), "OK")
+		}
+		assert.Equal(t, cli.Do("DBSIZE"), int64(1000))
+	})
+
+	t.Run("protocol error", func(t *testing.T) {
+		_, _, addr := newTestServer(t)
+		var cli = dial(t, addr)
+		_, _ = cli.conn.Write([]byte("*1\r\n+PING\r\n"))
+		assert.True(t, isError(cli.Read(), "ERR Protocol error"))
+		_, err := cli.r.ReadByte()
+		assert.Error(t, err)
+	})
+
+	t.Run("lengths", func(t *testing.T) {
+		_, _, addr := newTestServer(t)
+		var cli = dial(t, addr)
+		// 空数组被忽略
+		_, _ = cli.conn.Write([]byte("*-1\r\n*0\r\n"))
+		assert.Equal(t, cli.Do("PING"), "PONG")
+
+		_, _ = cli.conn.Write([]byte("*-2\r\n"))
+		assert.True(t, isError(cli.Read(), "ERR Protocol error: invalid length"))
+		_, err := cli.r.ReadByte()
+		assert.Error(t, err)
+
+		cli = dial(t, addr)
+		_, _ = cli.conn.Write([]byte("*1\r\n$-1\r\n"))
+		assert.True(t, isError(cli.Read(), "ERR Protocol error: invalid bulk length"))
+	})
+
+	t.Run("large bulk", func(t *testing.T) {
+		_, cache, addr := newTestServer(t)
+		var cli = dial(t, addr)
+		var value = strings.Repeat("x", 3*bulkChunk+1)
+		assert.Equal(t, cli.Do("SET", "a", value), "OK")
+		v, _ := cache.Get("a")
+		assert.Equal(t, string(v), value)
+		assert.Equal(t, cap(v), len(v))
+
+		// 声明的长度不会被预先分配, 连接断开时读取失败
+		_, _ = cli.conn.Write([]byte("*3\r\n$3\r\nSET\r\n$1\r\nb\r\n$536870912\r\nxyz"))
+		_ = cli.conn.Close()
+		assert.Eventually(t, func() bool { return cache.Len() == 1 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		var cache = memorycache.New[string, []byte](memorycache.WithWeigher(func(key string, value []byte) int64 {
+			if string(value) == "boom" {
+				panic("weigher")
+			}
+			return 1
+		}))
+		defer cache.Stop()
+		var srv = New(cache)
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		go func() { _ = srv.Serve(ln) }()
+		defer srv.Close()
+
+		var cli = dial(t, ln.Addr().String())
+		assert.True(t, isError(cli.Do("SET", "a", "boom"), "ERR internal error"))
+		_, err = cli.r.ReadByte()
+		assert.Error(t, err)
+
+		cli = dial(t, ln.Addr().String())
+		assert.Equal(t, cli.Do("SET", "a", "1"), "OK")
+	})
+
+	t.Run("quit", func(t *testing.T) {
+		_, _, addr := newTestServer(t)
+		var cli = dial(t, addr)
+		assert.Equal(t, cli.Do("QUIT"), "OK")
+		_, err := cli.r.ReadByte()
+		assert.Error(t, err)
+	})
+
+	t.Run("close", func(t *testing.T) {
+		var cache = memorycache.New[string, []byte]()
+		defer cache.Stop()
+		var srv = New(cache)
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		var done = make(chan error, 1)
+		go func() { done <- srv.Serve(ln) }()
+
+		var cli = dial(t, ln.Addr().String())
+		assert.Equal(t, cli.Do("PING"), "PONG")
+		assert.NoError(t, srv.Close())
+		assert.ErrorIs(t, <-done, ErrServerClosed)
+		_, err = cli.r.ReadByte()
+		assert.Error(t, err)
+
+		ln, err = net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		assert.ErrorIs(t, srv.Serve(ln), ErrServerClosed)
+	})
+}
+
+func TestMatch(t *testing.T) {
+	t.Run("", func(t *testing.T) {
+		assert.True(t, match("*", "anything"))
+		assert.True(t, match("user:*", "user:1"))
+		assert.False(t, match("user:*", "item:1"))
+		assert.True(t, match("h?llo", "hello"))
+		assert.False(t, match("h?llo", "hllo"))
+		assert.True(t, match("h[ae]llo", "hallo"))
+		assert.False(t, match("h[ae]llo", "hillo"))
+		assert.True(t, match("h[^e]llo", "hallo"))
+		assert.False(t, match("h[^e]llo", "hello"))
+		assert.True(t, match("h[a-c]llo", "hbllo"))
+		assert.True(t, match(`h\*llo`, "h*llo"))
+		assert.False(t, match(`h\*llo`, "hello"))
+		assert.True(t, match("*a*b", "xxaxxb"))
+		assert.False(t, match("*a*b", "xxaxxc"))
+	})
+}
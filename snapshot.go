@@ -0,0 +1,283 @@
+package memorycache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+	"time"
+)
+
+const (
+	snapshotMagic   = "MCSS"
+	snapshotVersion = 1
+
+	// 单个键或值编码后的最大长度, 用于拒绝损坏的快照
+	snapshotMaxField = 1 << 30
+)
+
+var (
+	ErrSnapshotFormat   = errors.New("memorycache: invalid snapshot format")
+	ErrSnapshotVersion  = errors.New("memorycache: unsupported snapshot version")
+	ErrSnapshotChecksum = errors.New("memorycache: snapshot checksum mismatch")
+)
+
+// Codec 键值的编解码器, 用于快照持久化
+// Key-value codec used by snapshot persistence
+type Codec[K comparable, V any] interface {
+	EncodeKey(key K) ([]byte, error)
+	DecodeKey(data []byte) (K, error)
+	EncodeValue(value V) ([]byte, error)
+	DecodeValue(data []byte) (V, error)
+}
+
+// JSONCodec 使用 encoding/json 的编解码器
+// Codec based on encoding/json
+type JSONCodec[K comparable, V any] struct{}
+
+func (JSONCodec[K, V]) EncodeKey(key K) ([]byte, error) { return json.Marshal(key) }
+
+func (JSONCodec[K, V]) DecodeKey(data []byte) (key K, err error) {
+	err = json.Unmarshal(data, &key)
+	return key, err
+}
+
+func (JSONCodec[K, V]) EncodeValue(value V) ([]byte, error) { return json.Marshal(value) }
+
+func (JSONCodec[K, V]) DecodeValue(data []byte) (value V, err error) {
+	err = json.Unmarshal(data, &value)
+	return value, err
+}
+
+// 快照中的一条记录, 时间为毫秒时间戳, math.MaxInt64表示永不过期
+type snapshotEntry[K comparable, V any] struct {
+	Key      K
+	Value    V
+	ExpireAt int64
+	StaleAt  int64
+}
+
+// SaveTo 将所有未过期的元素写入快照. 每个存储桶按淘汰顺序写出, 加载时可以还原访问顺序.
+// 格式: 魔数, 版本号, 若干条记录(键, 值, 过期时间, 软过期时间), 结束标记, 记录数量, CRC32校验和.
+// Write all live elements to a snapshot. Each bucket is written in eviction order, so loading restores the access order.
+// Format: magic, version, records (key, value, expiration time, soft expiration time), end marker, record count, CRC32 checksum.
+func (c *MemoryCache[K, V]) SaveTo(w io.Writer, codec Codec[K, V]) error {
+	var sw = &snapshotWriter{w: bufio.NewWriter(w), h: crc32.NewIEEE()}
+	sw.Write([]byte(snapshotMagic))
+	sw.Write([]byte{snapshotVersion})
+
+	var count uint64
+	var entries []snapshotEntry[K, V]
+	for _, b := range c.storage {
+		entries = c.collect(b, entries[:0])
+		for _, item := range entries {
+			key, err := codec.EncodeKey(item.Key)
+			if err != nil {
+				return err
+			}
+			value, err := codec.EncodeValue(item.Value)
+			if err != nil {
+				return err
+			}
+			sw.WriteUvarint(1)
+			sw.WriteBytes(key)
+			sw.WriteBytes(value)
+			sw.WriteVarint(item.ExpireAt)
+			sw.WriteVarint(item.StaleAt)
+			count++
+		}
+		if sw.err != nil {
+			return sw.err
+		}
+	}
+
+	sw.WriteUvarint(0)
+	sw.WriteUvarint(count)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], sw.h.Sum32())
+	sw.Write(sum[:])
+	if sw.err != nil {
+		return sw.err
+	}
+	return sw.w.Flush()
+}
+
+// 在锁内复制存储桶中未过期的元素, 编码在锁外进行
+func (c *MemoryCache[K, V]) collect(b *bucket[K, V], entries []snapshotEntry[K, V]) []snapshotEntry[K, V] {
+	b.Lock()
+	defer b.Unlock()
+
+	var now = c.getTimestamp()
+	b.List.Range(func(ele *Element[K, V]) bool {
+		if !ele.expired(now) {
+			entries = append(entries, snapshotEntry[K, V]{Key: ele.Key, Value: ele.Value, ExpireAt: ele.ExpireAt, StaleAt: ele.StaleAt})
+		}
+		return true
+	})
+	return entries
+}
+
+// LoadFrom 从快照加载元素. 校验通过后才会写入缓存; 已过期的元素被丢弃, 超出容量的元素按正常的淘汰策略处理.
+// Load elements from a snapshot. Nothing is written until the checksum is verified;
+// expired elements are dropped and elements over capacity are handled by the usual eviction policy.
+func (c *MemoryCache[K, V]) LoadFrom(r io.Reader, codec Codec[K, V]) error {
+	var sr = &snapshotReader{r: bufio.NewReader(r), h: crc32.NewIEEE()}
+	var header = make([]byte, len(snapshotMagic)+1)
+	if err := sr.ReadFull(header); err != nil {
+		return err
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return ErrSnapshotFormat
+	}
+	if header[len(snapshotMagic)] != snapshotVersion {
+		return ErrSnapshotVersion
+	}
+
+	var entries []snapshotEntry[K, V]
+	for {
+		tag, err := binary.ReadUvarint(sr)
+		if err != nil {
+			return sr.wrap(err)
+		}
+		if tag == 0 {
+			break
+		}
+		if tag != 1 {
+			return ErrSnapshotFormat
+		}
+
+		var item snapshotEntry[K, V]
+		key, err := sr.ReadBytes()
+		if err != nil {
+			return err
+		}
+		value, err := sr.ReadBytes()
+		if err != nil {
+			return err
+		}
+		if item.ExpireAt, err = binary.ReadVarint(sr); err != nil {
+			return sr.wrap(err)
+		}
+		if item.StaleAt, err = binary.ReadVarint(sr); err != nil {
+			return sr.wrap(err)
+		}
+		if item.Key, err = codec.DecodeKey(key); err != nil {
+			return err
+		}
+		if item.Value, err = codec.DecodeValue(value); err != nil {
+			return err
+		}
+		entries = append(entries, item)
+	}
+
+	count, err := binary.ReadUvarint(sr)
+	if err != nil {
+		return sr.wrap(err)
+	}
+	if count != uint64(len(entries)) {
+		return ErrSnapshotFormat
+	}
+	var expected = sr.h.Sum32()
+	var sum [4]byte
+	if _, err := io.ReadFull(sr.r, sum[:]); err != nil {
+		return sr.wrap(err)
+	}
+	if binary.BigEndian.Uint32(sum[:]) != expected {
+		return ErrSnapshotChecksum
+	}
+
+	var now = c.getTimestamp()
+	for _, item := range entries {
+		if item.ExpireAt <= now {
+			continue
+		}
+		c.set(item.Key, item.Value, remaining(item.StaleAt, now), remaining(item.ExpireAt, now), c.callback)
+	}
+	return nil
+}
+
+// 剩余存活时间, 永不过期时返回0. 已经过去的软过期时间按1ms处理, 使元素保持陈旧状态.
+func remaining(deadline, now int64) time.Duration {
+	if deadline == math.MaxInt64 {
+		return 0
+	}
+	if deadline <= now {
+		return time.Millisecond
+	}
+	return time.Duration(deadline-now) * time.Millisecond
+}
+
+// 写入时同时计算校验和, 记录第一个错误
+type snapshotWriter struct {
+	w   *bufio.Writer
+	h   hash.Hash32
+	err error
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (c *snapshotWriter) Write(p []byte) {
+	if c.err != nil {
+		return
+	}
+	_, _ = c.h.Write(p)
+	_, c.err = c.w.Write(p)
+}
+
+func (c *snapshotWriter) WriteUvarint(v uint64) {
+	c.Write(c.buf[:binary.PutUvarint(c.buf[:], v)])
+}
+
+func (c *snapshotWriter) WriteVarint(v int64) {
+	c.Write(c.buf[:binary.PutVarint(c.buf[:], v)])
+}
+
+func (c *snapshotWriter) WriteBytes(p []byte) {
+	c.WriteUvarint(uint64(len(p)))
+	c.Write(p)
+}
+
+// 读取时同时计算校验和
+type snapshotReader struct {
+	r *bufio.Reader
+	h hash.Hash32
+}
+
+func (c *snapshotReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		_, _ = c.h.Write([]byte{b})
+	}
+	return b, err
+}
+
+func (c *snapshotReader) ReadFull(p []byte) error {
+	if _, err := io.ReadFull(c.r, p); err != nil {
+		return c.wrap(err)
+	}
+	_, _ = c.h.Write(p)
+	return nil
+}
+
+func (c *snapshotReader) ReadBytes() ([]byte, error) {
+	n, err := binary.ReadUvarint(c)
+	if err != nil {
+		return nil, c.wrap(err)
+	}
+	if n > snapshotMaxField {
+		return nil, ErrSnapshotFormat
+	}
+	var p = make([]byte, n)
+	return p, c.ReadFull(p)
+}
+
+// 快照被截断时返回格式错误
+func (c *snapshotReader) wrap(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrSnapshotFormat
+	}
+	return err
+}
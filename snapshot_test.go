@@ -0,0 +1,110 @@
+package memorycache
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingCodec struct{ JSONCodec[string, int] }
+
+func (failingCodec) EncodeValue(value int) ([]byte, error) { return nil, errors.New("test") }
+
+func TestMemoryCache_Snapshot(t *testing.T) {
+	var codec = JSONCodec[string, int]{}
+
+	t.Run("round trip", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(4))
+		mc.Set("a", 1, time.Hour)
+		mc.Set("b", 2, 0)
+		mc.SetWithStale("c", 3, time.Minute, time.Hour)
+		mc.Set("d", 4, time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+
+		var buf = bytes.NewBuffer(nil)
+		assert.NoError(t, mc.SaveTo(buf, codec))
+
+		var mc2 = New[string, int](WithBucketNum(2))
+		assert.NoError(t, mc2.LoadFrom(bytes.NewReader(buf.Bytes()), codec))
+		assert.Equal(t, mc2.Len(), 3)
+
+		v, ok := mc2.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, v, 1)
+		_, ok = mc2.Get("d")
+		assert.False(t, ok)
+
+		var b = mc2.getBucket("a")
+		var ele = b.Find(b.hashcode, "a")
+		var src = mc.getBucket("a")
+		assert.Equal(t, ele.ExpireAt, src.Find(src.hashcode, "a").ExpireAt)
+
+		b = mc2.getBucket("b")
+		assert.Equal(t, b.Find(b.hashcode, "b").ExpireAt, int64(math.MaxInt64))
+
+		b = mc2.getBucket("c")
+		ele = b.Find(b.hashcode, "c")
+		assert.True(t, ele.StaleAt < ele.ExpireAt)
+	})
+
+	t.Run("expired while offline", func(t *testing.T) {
+		var mc = New[string, int]()
+		mc.Set("a", 1, 20*time.Millisecond)
+		mc.Set("b", 1, time.Hour)
+		var buf = bytes.NewBuffer(nil)
+		assert.NoError(t, mc.SaveTo(buf, codec))
+
+		time.Sleep(30 * time.Millisecond)
+		var mc2 = New[string, int]()
+		assert.NoError(t, mc2.LoadFrom(buf, codec))
+		assert.Equal(t, mc2.Len(), 1)
+	})
+
+	t.Run("bucket cap", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(1), WithBucketSize(0, 10))
+		for i := 0; i < 10; i++ {
+			mc.Set(string(rune('a'+i)), i, time.Hour)
+		}
+		mc.Get("a")
+		var buf = bytes.NewBuffer(nil)
+		assert.NoError(t, mc.SaveTo(buf, codec))
+
+		var mc2 = New[string, int](WithBucketNum(1), WithBucketSize(0, 3))
+		assert.NoError(t, mc2.LoadFrom(buf, codec))
+		assert.Equal(t, mc2.Len(), 3)
+		assert.Equal(t, getListKeys(mc2.storage[0].List), []string{"i", "j", "a"})
+	})
+
+	t.Run("corrupted", func(t *testing.T) {
+		var mc = New[string, int]()
+		mc.Set("a", 1, time.Hour)
+		var buf = bytes.NewBuffer(nil)
+		assert.NoError(t, mc.SaveTo(buf, codec))
+		var data = buf.Bytes()
+
+		var mc2 = New[string, int]()
+		var flipped = append([]byte(nil), data...)
+		flipped[7] ^= 0xFF
+		assert.Error(t, mc2.LoadFrom(bytes.NewReader(flipped), codec))
+		assert.Equal(t, mc2.Len(), 0)
+
+		var sum = append([]byte(nil), data...)
+		sum[len(sum)-1] ^= 0xFF
+		assert.ErrorIs(t, mc2.LoadFrom(bytes.NewReader(sum), codec), ErrSnapshotChecksum)
+
+		assert.ErrorIs(t, mc2.LoadFrom(bytes.NewReader(data[:len(data)-2]), codec), ErrSnapshotFormat)
+		assert.ErrorIs(t, mc2.LoadFrom(bytes.NewReader([]byte("XXXX\x01")), codec), ErrSnapshotFormat)
+		assert.ErrorIs(t, mc2.LoadFrom(bytes.NewReader([]byte(snapshotMagic+"\x09")), codec), ErrSnapshotVersion)
+		assert.Equal(t, mc2.Len(), 0)
+	})
+
+	t.Run("encode error", func(t *testing.T) {
+		var mc = New[string, int]()
+		mc.Set("a", 1, time.Hour)
+		assert.Error(t, mc.SaveTo(bytes.NewBuffer(nil), failingCodec{}))
+	})
+}
@@ -0,0 +1,31 @@
+package memorycache
+
+import "time"
+
+// SetWithStale 设置键值, 软过期时间和过期时间. hard<=0表示永不过期.
+// 超过软过期时间后, 值仍然可以被查询到, 但会被标记为陈旧数据, 并通过 WithLoader 注册的加载函数在后台重新加载;
+// 重新加载失败时继续返回陈旧数据, 直到超过过期时间被删除.
+// Set the key value, soft expiration time and expiration time. hard<=0 means never expire.
+// After the soft expiration time, the value can still be queried but is marked as stale, and is reloaded in the background
+// by the loader registered with WithLoader; if reloading fails, the stale value keeps being served until it expires.
+func (c *MemoryCache[K, V]) SetWithStale(key K, value V, soft, hard time.Duration) (exist bool) {
+	return c.set(key, value, soft, hard, c.callback)
+}
+
+// GetWithStale 查询缓存, 同时返回值是否为陈旧数据.
+// Query the cache and return whether the value is stale.
+func (c *MemoryCache[K, V]) GetWithStale(key K) (v V, stale, exist bool) {
+	var b = c.getBucket(key)
+	b.Lock()
+	defer b.Unlock()
+
+	ele, ok := c.fetch(b, key)
+	b.stats.hit(ok)
+	if !ok {
+		return v, false, false
+	}
+
+	b.Policy.Access(ele)
+	c.checkRefresh(ele)
+	return ele.Value, ele.stale(c.getTimestamp()), true
+}
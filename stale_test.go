@@ -0,0 +1,123 @@
+package memorycache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCache_SetWithStale(t *testing.T) {
+	t.Run("without loader", func(t *testing.T) {
+		var mc = New[string, int](WithCachedTime(false))
+		mc.SetWithStale("a", 1, 50*time.Millisecond, 150*time.Millisecond)
+
+		v, stale, ok := mc.GetWithStale("a")
+		assert.True(t, ok)
+		assert.False(t, stale)
+		assert.Equal(t, v, 1)
+
+		time.Sleep(100 * time.Millisecond)
+		v, stale, ok = mc.GetWithStale("a")
+		assert.True(t, ok)
+		assert.True(t, stale)
+		assert.Equal(t, v, 1)
+		_, ok = mc.Get("a")
+		assert.True(t, ok)
+
+		time.Sleep(100 * time.Millisecond)
+		_, _, ok = mc.GetWithStale("a")
+		assert.False(t, ok)
+	})
+
+	t.Run("revalidate", func(t *testing.T) {
+		var calls atomic.Int64
+		var mc = New[string, int](
+			WithCachedTime(false),
+			WithLoader(func(ctx context.Context, key string) (int, time.Duration, error) {
+				return int(calls.Add(1)) + 1, 50 * time.Millisecond, nil
+			}),
+		)
+		mc.SetWithStale("a", 1, 50*time.Millisecond, 200*time.Millisecond)
+
+		time.Sleep(100 * time.Millisecond)
+		v, stale, ok := mc.GetWithStale("a")
+		assert.True(t, ok)
+		assert.True(t, stale)
+		assert.Equal(t, v, 1)
+
+		time.Sleep(20 * time.Millisecond)
+		assert.Equal(t, calls.Load(), int64(1))
+		v, stale, ok = mc.GetWithStale("a")
+		assert.True(t, ok)
+		assert.False(t, stale)
+		assert.Equal(t, v, 2)
+
+		// 宽限期被保留
+		var b = mc.getBucket("a")
+		b.Lock()
+		ele := b.Find(b.hashcode, "a")
+		assert.Equal(t, ele.ExpireAt-ele.StaleAt, int64(150))
+		b.Unlock()
+	})
+
+	t.Run("stale if error", func(t *testing.T) {
+		var calls atomic.Int64
+		var mc = New[string, int](
+			WithCachedTime(false),
+			WithRefreshRetryInterval(10*time.Millisecond),
+			WithLoader(func(ctx context.Context, key string) (int, time.Duration, error) {
+				calls.Add(1)
+				return 0, 0, errors.New("test")
+			}),
+		)
+		mc.SetWithStale("a", 1, 50*time.Millisecond, 200*time.Millisecond)
+
+		time.Sleep(100 * time.Millisecond)
+		for i := 0; i < 3; i++ {
+			v, stale, ok := mc.GetWithStale("a")
+			assert.True(t, ok)
+			assert.True(t, stale)
+			assert.Equal(t, v, 1)
+			time.Sleep(20 * time.Millisecond)
+		}
+		assert.Equal(t, calls.Load(), int64(3))
+
+		time.Sleep(100 * time.Millisecond)
+		_, ok := mc.Get("a")
+		assert.False(t, ok)
+	})
+
+	t.Run("expired callback", func(t *testing.T) {
+		var mc = New[string, int](
+			WithCachedTime(false),
+			WithInterval(10*time.Millisecond, 10*time.Millisecond),
+		)
+		var reasons = make(chan Reason, 1)
+		mc.callback = func(ele *Element[string, int], reason Reason) {
+			reasons <- reason
+		}
+		mc.SetWithStale("a", 1, 20*time.Millisecond, 50*time.Millisecond)
+		time.Sleep(30 * time.Millisecond)
+		assert.Equal(t, mc.Len(), 1)
+		assert.Equal(t, <-reasons, ReasonExpired)
+		assert.Equal(t, mc.Len(), 0)
+	})
+
+	t.Run("never expire", func(t *testing.T) {
+		var mc = New[string, int](WithCachedTime(false))
+		mc.SetWithStale("a", 1, 20*time.Millisecond, -1)
+		time.Sleep(50 * time.Millisecond)
+		v, stale, ok := mc.GetWithStale("a")
+		assert.True(t, ok)
+		assert.True(t, stale)
+		assert.Equal(t, v, 1)
+
+		mc.SetWithStale("b", 1, -1, time.Hour)
+		_, stale, _ = mc.GetWithStale("b")
+		assert.False(t, stale)
+	})
+}
@@ -0,0 +1,186 @@
+package memorycache
+
+import "time"
+
+// Stats 统计数据快照
+// Statistics snapshot
+type Stats struct {
+	// 命中次数
+	// Number of hits
+	Hits uint64
+
+	// 未命中次数
+	// Number of misses
+	Misses uint64
+
+	// 新增元素次数
+	// Number of inserted elements
+	Sets uint64
+
+	// 更新元素次数
+	// Number of updated elements
+	Updates uint64
+
+	// 调用删除的次数, 等于 Evictions(ReasonDeleted)
+	// Number of deletions, equal to Evictions(ReasonDeleted)
+	Deletes uint64
+
+	// 被准入策略或开销上限拒绝的写入次数
+	// Number of writes rejected by the admission policy or the cost limit
+	Rejects uint64
+
+	// 后台过期检查的次数和累计耗时, 不需要开启统计
+	// Number of background expiration checks and their cumulative duration, recorded even if statistics are not enabled
+	JanitorRuns     uint64
+	JanitorDuration time.Duration
+
+	// 异步回调队列已满时被丢弃的通知数量, 不需要开启统计
+	// Number of notifications discarded because the asynchronous callback queue was full, recorded even if statistics are not enabled
+	DroppedCallbacks uint64
+
+	// 按原因统计的删除次数
+	removals [reasonNum]uint64
+}
+
+// BucketInfo 存储桶状态
+// Bucket status
+type BucketInfo struct {
+	// 链表中的元素数量
+	// Number of elements in the list
+	Len int
+
+	// 堆中的元素数量
+	// Number of elements in the heap
+	HeapLen int
+
+	// 元素的总开销
+	// Total cost of elements
+	Cost int64
+
+	// 堆顶元素的过期时间, 毫秒. 存储桶为空时为0, 永不过期时为math.MaxInt64
+	// Expiration time of the top element of the heap, in milliseconds. 0 if the bucket is empty, math.MaxInt64 if it never expires
+	NextExpireAt int64
+}
+
+// Evictions 按原因获取元素被删除的次数
+// Get the number of removed elements by reason
+func (c Stats) Evictions(reason Reason) uint64 {
+	if int(reason) >= len(c.removals) {
+		return 0
+	}
+	return c.removals[reason]
+}
+
+// HitRatio 命中率
+// Hit ratio
+func (c Stats) HitRatio() float64 {
+	var total = c.Hits + c.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(c.Hits) / float64(total)
+}
+
+func (c *Stats) merge(d *counters) {
+	c.Hits += d.hits
+	c.Misses += d.misses
+	c.Sets += d.sets
+	c.Updates += d.updates
+	c.Rejects += d.rejects
+	for i, v := range d.removals {
+		c.removals[i] += v
+	}
+	c.Deletes = c.removals[ReasonDeleted]
+}
+
+// 存储桶的统计计数, 由存储桶的锁保护. 为nil时不做统计.
+type counters struct {
+	hits, misses, sets, updates, rejects uint64
+	removals                             [reasonNum]uint64
+}
+
+func (c *counters) hit(ok bool) {
+	if c == nil {
+		return
+	}
+	if ok {
+		c.hits++
+	} else {
+		c.misses++
+	}
+}
+
+func (c *counters) set() {
+	if c != nil {
+		c.sets++
+	}
+}
+
+func (c *counters) update() {
+	if c != nil {
+		c.updates++
+	}
+}
+
+func (c *counters) reject() {
+	if c != nil {
+		c.rejects++
+	}
+}
+
+func (c *counters) remove(reason Reason) {
+	if c != nil && int(reason) < len(c.removals) {
+		c.removals[reason]++
+	}
+}
+
+// Stats 获取统计数据快照. 未开启统计时, 除后台检查和丢弃的回调外的计数均为零.
+// Get a snapshot of statistics. If statistics are not enabled, all counters except the background checks and dropped callbacks are zero.
+func (c *MemoryCache[K, V]) Stats() Stats {
+	var s = Stats{
+		JanitorRuns:      uint64(c.janitorRuns.Load()),
+		JanitorDuration:  time.Duration(c.janitorNanos.Load()),
+		DroppedCallbacks: c.dispatcher.dropped(),
+	}
+	for _, b := range c.storage {
+		b.Lock()
+		if b.stats != nil {
+			s.merge(b.stats)
+		}
+		b.Unlock()
+	}
+	return s
+}
+
+// ResetStats 清零统计数据
+// Reset statistics to zero
+func (c *MemoryCache[K, V]) ResetStats() {
+	c.janitorRuns.Store(0)
+	c.janitorNanos.Store(0)
+	if c.dispatcher != nil {
+		c.dispatcher.drops.Store(0)
+	}
+	for _, b := range c.storage {
+		b.Lock()
+		if b.stats != nil {
+			*b.stats = counters{}
+		}
+		b.Unlock()
+	}
+}
+
+// Buckets 获取每个存储桶的状态
+// Get the status of each bucket
+func (c *MemoryCache[K, V]) Buckets() []BucketInfo {
+	var list = make([]BucketInfo, 0, len(c.storage))
+	for _, b := range c.storage {
+		b.Lock()
+		var info = BucketInfo{Len: b.List.Len(), HeapLen: b.Heap.Len(), Cost: b.cost}
+		if b.Heap.Len() > 0 {
+			info.NextExpireAt = b.Heap.Front().ExpireAt
+		}
+		b.Unlock()
+		list = append(list, info)
+	}
+	return list
+}
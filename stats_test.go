@@ -0,0 +1,102 @@
+package memorycache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCache_Stats(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		var mc = New[string, int]()
+		mc.Set("a", 1, time.Hour)
+		mc.Get("a")
+		assert.Equal(t, mc.Stats(), Stats{})
+		assert.Equal(t, mc.Stats().HitRatio(), 0.0)
+	})
+
+	t.Run("counters", func(t *testing.T) {
+		var mc = New[string, int](
+			WithStats(true),
+			WithBucketNum(1),
+			WithBucketSize(0, 2),
+			WithCachedTime(false),
+		)
+		mc.Set("a", 1, time.Hour)
+		mc.Set("a", 2, time.Hour)
+		mc.Set("b", 1, time.Millisecond)
+		mc.Get("a")
+		mc.Get("c")
+		mc.GetWithTTL("a", time.Hour)
+		mc.GetOrCreate("a", 1, time.Hour)
+		time.Sleep(10 * time.Millisecond)
+		_, _, _ = mc.GetWithStale("b")
+		mc.Set("c", 1, time.Hour)
+		mc.Set("d", 1, time.Hour)
+		mc.Delete("c")
+		mc.Delete("e")
+
+		var s = mc.Stats()
+		assert.Equal(t, s.Hits, uint64(3))
+		assert.Equal(t, s.Misses, uint64(2))
+		assert.Equal(t, s.Sets, uint64(4))
+		assert.Equal(t, s.Updates, uint64(1))
+		assert.Equal(t, s.Deletes, uint64(1))
+		assert.Equal(t, s.Evictions(ReasonExpired), uint64(1))
+		assert.Equal(t, s.Evictions(ReasonEvicted), uint64(1))
+		assert.Equal(t, s.Evictions(ReasonDeleted), uint64(1))
+		assert.Equal(t, s.Evictions(Reason(255)), uint64(0))
+		assert.Equal(t, s.Evictions(ReasonCleared), uint64(0))
+		assert.Equal(t, s.HitRatio(), 0.6)
+
+		mc.ResetStats()
+		assert.Equal(t, mc.Stats(), Stats{})
+	})
+
+	t.Run("get or load", func(t *testing.T) {
+		var mc = New[string, int](WithStats(true))
+		var loader = func(ctx context.Context, key string) (int, time.Duration, error) {
+			return 1, time.Hour, nil
+		}
+		mc.GetOrLoad(context.Background(), "a", loader)
+		mc.GetOrLoad(context.Background(), "a", loader)
+		var s = mc.Stats()
+		assert.Equal(t, s.Hits, uint64(1))
+		assert.Equal(t, s.Misses, uint64(1))
+		assert.Equal(t, s.Sets, uint64(1))
+	})
+
+	t.Run("rejects", func(t *testing.T) {
+		var mc = New[string, int](WithStats(true), WithBucketNum(1), WithMaxCost(1), WithWeigher(func(key string, value int) int64 {
+			return int64(value)
+		}))
+		mc.Set("a", 2, time.Hour)
+		assert.Equal(t, mc.Stats().Rejects, uint64(1))
+		assert.Equal(t, mc.Len(), 0)
+	})
+}
+
+func TestMemoryCache_Buckets(t *testing.T) {
+	var mc = New[string, int](WithBucketNum(1), WithCachedTime(false))
+	assert.Equal(t, mc.Buckets(), []BucketInfo{{}})
+
+	mc.Set("a", 1, time.Hour)
+	mc.Set("b", 1, -1)
+	var list = mc.Buckets()
+	assert.Equal(t, len(list), 1)
+	assert.Equal(t, list[0].Len, 2)
+	assert.Equal(t, list[0].HeapLen, 2)
+	assert.Equal(t, list[0].Cost, int64(2))
+	assert.True(t, list[0].NextExpireAt > time.Now().UnixMilli())
+}
+
+func TestReason_String(t *testing.T) {
+	assert.Equal(t, ReasonExpired.String(), "expired")
+	assert.Equal(t, ReasonEvicted.String(), "evicted")
+	assert.Equal(t, ReasonDeleted.String(), "deleted")
+	assert.Equal(t, ReasonCleared.String(), "cleared")
+	assert.Equal(t, ReasonClosed.String(), "closed")
+	assert.Equal(t, Reason(255).String(), "unknown")
+}
@@ -0,0 +1,89 @@
+package memorycache
+
+import (
+	"math"
+	"time"
+
+	"github.com/lxzan/dao/algo"
+)
+
+// NoExpiration TTL 对永不过期的键返回的剩余存活时间
+// Remaining time to live returned by TTL for keys that never expire
+const NoExpiration time.Duration = -1
+
+// TTL 获取剩余存活时间, 永不过期时返回 NoExpiration. 即将过期的键返回非负值. 不影响元素在淘汰策略中的位置.
+// Get the remaining time to live, or NoExpiration if the element never expires. Keys about to expire return a non-negative value.
+// The position of the element in the eviction policy is not affected.
+func (c *MemoryCache[K, V]) TTL(key K) (time.Duration, bool) {
+	var b = c.getBucket(key)
+	b.Lock()
+	defer b.Unlock()
+
+	ele, ok := c.fetch(b, key)
+	if !ok {
+		return 0, false
+	}
+	if ele.ExpireAt == math.MaxInt64 {
+		return NoExpiration, true
+	}
+	return time.Duration(ele.ExpireAt-c.getTimestamp()) * time.Millisecond, true
+}
+
+// Expire 更新过期时间, d<=0表示永不过期. 返回键是否存在. 不修改值, 也不影响元素在淘汰策略中的位置.
+// Update the expiration time, d<=0 means never expire. Returns whether the key exists.
+// The value and the position of the element in the eviction policy are not changed.
+func (c *MemoryCache[K, V]) Expire(key K, d time.Duration) bool {
+	return c.expire(key, c.getExp(d), c.getRefreshAt(d))
+}
+
+// ExpireAt 将过期时间设置为t, t为零值表示永不过期, t已经过去时删除元素. 返回键是否存在.
+// 不修改值, 也不影响元素在淘汰策略中的位置.
+// Set the expiration time to t, a zero t means never expire, and the element is deleted if t has already passed.
+// Returns whether the key exists. The value and the position of the element in the eviction policy are not changed.
+func (c *MemoryCache[K, V]) ExpireAt(key K, t time.Time) bool {
+	if t.IsZero() {
+		return c.expire(key, math.MaxInt64, math.MaxInt64)
+	}
+	// 剩余存活时间不能超过 time.Duration 的范围, 否则计算时会溢出
+	var now = c.getTimestamp()
+	var expireAt = algo.Min(t.UnixMilli(), now+int64(math.MaxInt64/time.Millisecond))
+	return c.expire(key, expireAt, c.getRefreshAt(time.Duration(expireAt-now)*time.Millisecond))
+}
+
+// Persist 移除过期时间, 使元素永不过期. 返回是否移除了过期时间, 键不存在或已经永不过期时返回false.
+// Remove the expiration time so that the element never expires. Returns whether an expiration time was removed,
+// false if the key does not exist or already never expires.
+func (c *MemoryCache[K, V]) Persist(key K) bool {
+	var b = c.getBucket(key)
+	b.Lock()
+	defer b.Unlock()
+
+	ele, ok := c.fetch(b, key)
+	if !ok || ele.ExpireAt == math.MaxInt64 {
+		return false
+	}
+	ele.refreshAt = math.MaxInt64
+	b.SetTTL(ele, math.MaxInt64)
+	b.aof.expire(key, math.MaxInt64)
+	return true
+}
+
+// 更新过期时间, 新的过期时间已经过去时删除元素. 返回键是否存在.
+func (c *MemoryCache[K, V]) expire(key K, expireAt, refreshAt int64) bool {
+	var b = c.getBucket(key)
+	b.Lock()
+	defer b.Unlock()
+
+	ele, ok := c.fetch(b, key)
+	if !ok {
+		return false
+	}
+	if expireAt <= c.getTimestamp() {
+		b.Delete(ele, ReasonExpired)
+		return true
+	}
+	ele.refreshAt = refreshAt
+	b.SetTTL(ele, expireAt)
+	b.aof.expire(key, expireAt)
+	return true
+}
@@ -0,0 +1,127 @@
+package memorycache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCache_TTL(t *testing.T) {
+	var mc = New[string, int](WithCachedTime(false))
+	defer mc.Stop()
+	mc.Set("a", 1, time.Hour)
+	mc.Set("b", 1, 0)
+
+	d, ok := mc.TTL("a")
+	assert.True(t, ok)
+	assert.True(t, d > 59*time.Minute && d <= time.Hour)
+
+	d, ok = mc.TTL("b")
+	assert.True(t, ok)
+	assert.Equal(t, d, NoExpiration)
+
+	_, ok = mc.TTL("c")
+	assert.False(t, ok)
+}
+
+func TestMemoryCache_Expire(t *testing.T) {
+	t.Run("", func(t *testing.T) {
+		var mc = New[string, int](WithCachedTime(false))
+		defer mc.Stop()
+		mc.Set("a", 1, time.Hour)
+		assert.True(t, mc.Expire("a", time.Millisecond))
+		assert.False(t, mc.Expire("b", time.Hour))
+		time.Sleep(5 * time.Millisecond)
+		_, ok := mc.Get("a")
+		assert.False(t, ok)
+
+		mc.Set("c", 1, time.Millisecond)
+		assert.True(t, mc.Expire("c", 0))
+		time.Sleep(5 * time.Millisecond)
+		d, ok := mc.TTL("c")
+		assert.True(t, ok)
+		assert.Equal(t, d, NoExpiration)
+	})
+
+	t.Run("keep position", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(1), WithBucketSize(0, 2))
+		defer mc.Stop()
+		mc.Set("a", 1, time.Hour)
+		mc.Set("b", 1, time.Hour)
+		assert.True(t, mc.Expire("a", 2*time.Hour))
+		assert.True(t, mc.Persist("a"))
+		mc.Set("c", 1, time.Hour)
+		_, ok := mc.Get("a")
+		assert.False(t, ok)
+	})
+}
+
+func TestMemoryCache_ExpireAt(t *testing.T) {
+	t.Run("", func(t *testing.T) {
+		var mc = New[string, int](WithCachedTime(false), WithStats(true))
+		defer mc.Stop()
+		mc.Set("a", 1, time.Minute)
+		assert.True(t, mc.ExpireAt("a", time.Now().Add(time.Hour)))
+		d, ok := mc.TTL("a")
+		assert.True(t, ok)
+		assert.True(t, d > 59*time.Minute && d <= time.Hour)
+		assert.False(t, mc.ExpireAt("b", time.Now().Add(time.Hour)))
+
+		assert.True(t, mc.ExpireAt("a", time.Time{}))
+		d, _ = mc.TTL("a")
+		assert.Equal(t, d, NoExpiration)
+
+		// 超出 time.Duration 范围的时间被截断
+		assert.True(t, mc.ExpireAt("a", time.Unix(1<<40, 0)))
+		d, _ = mc.TTL("a")
+		assert.True(t, d > 290*365*24*time.Hour)
+
+		assert.True(t, mc.ExpireAt("a", time.Now().Add(-time.Second)))
+		_, ok = mc.TTL("a")
+		assert.False(t, ok)
+		assert.Equal(t, mc.Stats().Evictions(ReasonExpired), uint64(1))
+		assert.Equal(t, mc.Stats().Hits+mc.Stats().Misses, uint64(0))
+	})
+
+	t.Run("heap", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(1), WithCachedTime(false))
+		defer mc.Stop()
+		mc.Set("a", 1, time.Hour)
+		mc.Set("b", 2, 2*time.Hour)
+		assert.True(t, mc.ExpireAt("b", time.Now().Add(10*time.Millisecond)))
+		assert.Equal(t, mc.storage[0].Heap.Front().Key, "b")
+		time.Sleep(20 * time.Millisecond)
+		assert.Equal(t, mc.storage[0].Check(time.Now().UnixMilli(), 10), 1)
+		v, ok := mc.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, v, 1)
+	})
+
+	t.Run("keep position", func(t *testing.T) {
+		var mc = New[string, int](WithBucketNum(1), WithBucketSize(0, 2))
+		defer mc.Stop()
+		mc.Set("a", 1, time.Hour)
+		mc.Set("b", 1, time.Hour)
+		assert.True(t, mc.ExpireAt("a", time.Now().Add(2*time.Hour)))
+		mc.Set("c", 1, time.Hour)
+		_, ok := mc.Get("a")
+		assert.False(t, ok)
+	})
+}
+
+func TestMemoryCache_Persist(t *testing.T) {
+	var mc = New[string, int](WithCachedTime(false))
+	defer mc.Stop()
+	mc.Set("a", 1, 10*time.Millisecond)
+	mc.Set("b", 1, 0)
+	assert.True(t, mc.Persist("a"))
+	assert.False(t, mc.Persist("a"))
+	assert.False(t, mc.Persist("b"))
+	assert.False(t, mc.Persist("c"))
+
+	time.Sleep(20 * time.Millisecond)
+	v, ok := mc.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, v, 1)
+}
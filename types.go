@@ -1,5 +1,10 @@
 package memorycache
 
+import (
+	"context"
+	"time"
+)
+
 // Reason 回调函数触发原因
 type Reason uint8
 
@@ -7,10 +12,39 @@ const (
 	ReasonExpired = Reason(0) // 过期
 	ReasonEvicted = Reason(1) // 被驱逐
 	ReasonDeleted = Reason(2) // 被删除
+	ReasonCleared = Reason(3) // 被清空
+	ReasonClosed  = Reason(4) // 缓存被关闭
+
+	reasonNum = 5 // 回调原因的数量
 )
 
+func (c Reason) String() string {
+	switch c {
+	case ReasonExpired:
+		return "expired"
+	case ReasonEvicted:
+		return "evicted"
+	case ReasonDeleted:
+		return "deleted"
+	case ReasonCleared:
+		return "cleared"
+	case ReasonClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
 type CallbackFunc[T any] func(element T, reason Reason)
 
+// WeigherFunc 计算键值的开销
+// Calculate the cost of a key-value pair
+type WeigherFunc[K comparable, V any] func(key K, value V) int64
+
+// LoaderFunc 数据加载函数, 返回值, 过期时间和错误. 过期时间<=0表示永不过期.
+// Data loading function, returns value, expiration time and error. An expiration time <= 0 means never expire.
+type LoaderFunc[K comparable, V any] func(ctx context.Context, key K) (V, time.Duration, error)
+
 type Element[K comparable, V any] struct {
 	// 地址
 	prev, addr, next pointer
@@ -21,6 +55,15 @@ type Element[K comparable, V any] struct {
 	// 哈希
 	hashcode uint64
 
+	// 哈希冲突链表, 指向下一个相同哈希的元素
+	link pointer
+
+	// 访问频率或访问标记, 由淘汰策略维护
+	freq uint32
+
+	// 开销
+	cost int64
+
 	// 回调函数
 	cb CallbackFunc[*Element[K, V]]
 
@@ -32,8 +75,28 @@ type Element[K comparable, V any] struct {
 
 	// 过期时间, 毫秒
 	ExpireAt int64
+
+	// 软过期时间, 毫秒. 超过软过期时间的值被视为陈旧数据, 超过过期时间才会被删除.
+	StaleAt int64
+
+	// 提前刷新时间, 毫秒
+	refreshAt int64
+
+	// 写入版本, 值每次写入时更新, 用于丢弃过时的刷新结果
+	version uint64
 }
 
 func (c *Element[K, V]) expired(now int64) bool {
 	return now > c.ExpireAt
 }
+
+func (c *Element[K, V]) stale(now int64) bool {
+	return now > c.StaleAt
+}
+
+// Entry 键值对, 用于批量写入
+// Key-value pair used by batch writes
+type Entry[K comparable, V any] struct {
+	Key   K
+	Value V
+}